@@ -3,3 +3,7 @@ package aof
 func Open(name string, geometry Geometry, recovery Recovery) (aof *AOF, err error) {
 	return instance.Open(name, geometry, recovery)
 }
+
+func OpenLog(name string, config LogConfig) (*Log, error) {
+	return instance.OpenLog(name, config)
+}
@@ -13,6 +13,7 @@ import (
 
 var (
 	ErrAppendFuncNil = errors.New("append func nil")
+	ErrMagicDisabled = errors.New("magic disabled")
 )
 
 type AppendEvent struct {
@@ -242,8 +243,82 @@ func (aof *AOF) Write(b []byte) (int, error) {
 	return len(b), nil
 }
 
-func (aof *AOF) Checkpoint() (int64, error) {
-	return 0, nil
+// Checkpoint commits everything written so far by writing the Checkpoint magic at the
+// tail followed by a new Tail magic. The returned offset is the end of the committed
+// data and the position of the Checkpoint magic which is part of the file contents
+// seen by Tailers. ReadEvent.Checkpoints reports it so raw consumers can skip it. If
+// sync is true the range since the last sync is msync'd before returning.
+//
+// When the Tail magic cannot be found on recovery the file is rolled back to the last
+// checkpoint. See RecoveryResult.Checkpoint.
+func (aof *AOF) Checkpoint(sync bool) (int64, error) {
+	if aof.err != nil {
+		return 0, aof.err
+	}
+	if !aof.recovery.Magic.IsEnabled() {
+		return 0, ErrMagicDisabled
+	}
+	aof.writeMu.Lock()
+	defer aof.writeMu.Unlock()
+	if aof.state != FileStateOpened {
+		return 0, os.ErrClosed
+	}
+	var (
+		offset  = atomic.LoadInt64(&aof.size)
+		newSize = offset + 16
+	)
+	if newSize > int64(len(aof.data)) {
+		return 0, io.EOF
+	}
+	if aof.f != nil {
+		fileSize := atomic.LoadInt64(&aof.fileSize)
+		if newSize > fileSize {
+			aof.stats.blockingCount.Incr()
+			begin := timex.NanoTime()
+			aof.err = aof.truncate(aof.geometry.Next(newSize))
+			elapsed := timex.NanoTime() - begin
+			aof.stats.blockingDur.Add(elapsed)
+			aof.stats.truncDur.Add(elapsed)
+			aof.stats.truncCount.Incr()
+			aof.m.stats.Truncates.Incr()
+			aof.m.stats.TruncatesDur.Add(elapsed)
+			if aof.err != nil {
+				aof.stats.truncErrDur.Add(elapsed)
+				aof.stats.truncErrCount.Incr()
+				aof.m.stats.TruncateErrors.Incr()
+				aof.m.stats.TruncateErrorsDur.Add(elapsed)
+				return 0, aof.err
+			}
+		}
+	}
+	write64LE(unsafe.Pointer(&aof.data[offset]), aof.recovery.Magic.Checkpoint)
+	write64LE(unsafe.Pointer(&aof.data[offset+8]), aof.recovery.Magic.Tail)
+	var checkpoints []int64
+	if p := aof.checkpoints.Load(); p != nil {
+		checkpoints = *p
+	}
+	checkpoints = append(checkpoints, offset)
+	aof.checkpoints.Store(&checkpoints)
+	atomic.StoreInt64(&aof.size, offset+8)
+	atomic.StoreInt64(&aof.checkpoint, offset)
+
+	var err error
+	if sync && aof.f != nil {
+		// msync requires a page aligned address.
+		begin := timex.NanoTime()
+		err = aof.data[(aof.syncSize/pageSize)*pageSize : newSize].Flush()
+		elapsed := timex.NanoTime() - begin
+		aof.m.stats.Syncs.Incr()
+		aof.m.stats.SyncsDur.Add(elapsed)
+		if err != nil {
+			aof.m.stats.SyncErrors.Incr()
+			aof.m.stats.SyncErrorsDur.Add(elapsed)
+		} else {
+			aof.syncSize = offset + 8
+		}
+	}
+	_ = aof.Wake()
+	return offset, err
 }
 
 func (aof *AOF) WriteNonBlocking(b []byte) (int, error) {
@@ -411,7 +486,7 @@ func (aof *AOF) completeAppend(reserve int64, event AppendEvent, fn AppendFunc)
 		}
 		// Write magic tail
 		if aof.recovery.Magic.IsEnabled() {
-			write64LE(unsafe.Pointer(&aof.data[n]), aof.recovery.Magic.Tail)
+			write64LE(unsafe.Pointer(&aof.data[event.Begin+n]), aof.recovery.Magic.Tail)
 		}
 		atomic.StoreInt64(&aof.size, event.Begin+n)
 		return nil
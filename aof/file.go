@@ -1,6 +1,7 @@
 package aof
 
 import (
+	"encoding/binary"
 	"errors"
 	"github.com/moontrade/kirana/pkg/counter"
 	"github.com/moontrade/kirana/pkg/mmap"
@@ -11,6 +12,7 @@ import (
 	"golang.org/x/sys/cpu"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"unsafe"
@@ -117,11 +119,16 @@ type AOF struct {
 	fileSize   int64
 	flushSize  int64
 	syncSize   int64
+	checkpoint int64
 	flushIndex int
 	gcIndex    int
 	gc         bool
 	state      FileState
 	created    bool
+
+	// checkpoints holds the offsets of the Checkpoint magics in ascending order.
+	// Offsets are only appended under writeMu so a loaded slice never changes.
+	checkpoints atomic.Pointer[[]int64]
 }
 
 func alignToPageSize(size int64) int64 {
@@ -137,6 +144,42 @@ func alignToPageSize(size int64) int64 {
 
 func (aof *AOF) IsAnonymous() bool { return aof.f == nil }
 
+func (aof *AOF) Name() string { return aof.name }
+
+// Manager returns the Manager the file was opened with.
+func (aof *AOF) Manager() *Manager { return aof.m }
+
+// Size returns the size of the contents written so far.
+func (aof *AOF) Size() int64 { return atomic.LoadInt64(&aof.size) }
+
+// Contents returns the contents written so far. The slice references the mapping and
+// must not be used after the file is closed.
+func (aof *AOF) Contents() []byte { return aof.data[0:atomic.LoadInt64(&aof.size)] }
+
+// Recovery returns the result of recovering the file when it was opened.
+func (aof *AOF) Recovery() RecoveryResult { return aof.recovery.result }
+
+// LastCheckpoint returns the offset of the last Checkpoint or 0 if there is none.
+func (aof *AOF) LastCheckpoint() int64 { return atomic.LoadInt64(&aof.checkpoint) }
+
+// checkpointsIn returns the offsets of the Checkpoint magics in [begin, end).
+func (aof *AOF) checkpointsIn(begin, end int64) []int64 {
+	p := aof.checkpoints.Load()
+	if p == nil {
+		return nil
+	}
+	offsets := *p
+	lo := sort.Search(len(offsets), func(i int) bool { return offsets[i] >= begin })
+	hi := sort.Search(len(offsets), func(i int) bool { return offsets[i] >= end })
+	if lo == hi {
+		return nil
+	}
+	return offsets[lo:hi:hi]
+}
+
+// LiveStats returns the FileStats that are being updated. Fields must be read atomically.
+func (aof *AOF) LiveStats() *FileStats { return &aof.stats }
+
 func getGCIndex(aof *AOF) int { return aof.gcIndex }
 func setGCIndex(aof *AOF, index int) {
 	aof.gc = true
@@ -340,64 +383,70 @@ func (m *Manager) Open(name string, geometry Geometry, recovery Recovery) (aof *
 			}
 			return aof, err
 		}
-		aof.size = aof.recovery.tail
-
 		switch result.Outcome {
 		case Corrupted:
 			aof.err = ErrCorrupted
 			return aof, ErrCorrupted
 
 		case Tail:
-			aof.size = result.Tail - 8
+			aof.size = result.Tail
+			aof.checkpoint = result.Checkpoint
+			// A Checkpoint of 0 is ambiguous with none being found.
+			if result.Checkpoint+8 <= aof.size &&
+				binary.LittleEndian.Uint64(data[result.Checkpoint:]) == aof.recovery.Magic.Checkpoint {
+				aof.checkpoints.Store(&[]int64{result.Checkpoint})
+			}
 
 		case Checkpoint:
+			// The Tail magic was not found. Roll back to the last checkpoint and
+			// discard anything partially written after it.
 			aof.size = result.Checkpoint + 8
-			if result.Tail > result.Checkpoint+8 {
-				// Warn
+			aof.checkpoint = result.Checkpoint
+			aof.checkpoints.Store(&[]int64{result.Checkpoint})
+			discard := data[aof.size:result.Tail]
+			for i := range discard {
+				discard[i] = 0
 			}
-			// Truncate to size if needed
-			if aof.fileSize > aof.size {
-				// Final size
-				finalSize := aof.size
-				if aof.recovery.Magic.Checkpoint > 0 {
-					finalSize += 8
-				}
-				if finalSize != aof.fileSize {
-					before := timex.NanoTime()
-					aof.err = os.Truncate(path, finalSize)
-					elapsed := timex.NanoTime() - before
-					aof.m.stats.Truncates.Incr()
-					aof.m.stats.TruncatesDur.Add(elapsed)
-					if aof.err != nil {
-						aof.m.stats.TruncateErrors.Incr()
-						aof.m.stats.TruncateErrorsDur.Add(elapsed)
-					}
-
-					// Remap to size?
-					mapSize := alignToPageSize(finalSize)
-					if mapSize > int64(len(data)) {
-						_ = data.Unmap()
-						data = nil
-						data, aof.err = mmap.MapRegion(aof.f, int(mapSize), mmap.RDONLY, 0, 0)
-						if aof.err != nil {
-							return aof, aof.err
-						}
-					}
+			if aof.size+8 > aof.fileSize {
+				before := timex.NanoTime()
+				aof.err = aof.truncate(aof.geometry.Next(aof.size + 8))
+				elapsed := timex.NanoTime() - before
+				aof.m.stats.Truncates.Incr()
+				aof.m.stats.TruncatesDur.Add(elapsed)
+				if aof.err != nil {
+					aof.m.stats.TruncateErrors.Incr()
+					aof.m.stats.TruncateErrorsDur.Add(elapsed)
+					_ = data.Unmap()
+					return aof, aof.err
 				}
 			}
-
-			if aof.err == nil {
-				aof.state.store(FileStateEOF)
-			}
+			write64LE(unsafe.Pointer(&data[aof.size]), aof.recovery.Magic.Tail)
 		}
+		aof.recovery.tail = aof.size
+		aof.syncSize = aof.size
 		aof.data = data
 	} else {
+		if aof.state == FileStateEOF {
+			aof.size = finishedSize(aof.fileSize, data, aof.recovery.Magic)
+		}
 		aof.data = data
 	}
 
 	return aof, nil
 }
 
+// finishedSize returns the size of the contents of a finished file excluding the
+// Checkpoint magic appended by Finish.
+func finishedSize(fileSize int64, data []byte, magic Magic) int64 {
+	if magic.Checkpoint == 0 || fileSize < 8 || fileSize > int64(len(data)) {
+		return fileSize
+	}
+	if binary.LittleEndian.Uint64(data[fileSize-8:]) == magic.Checkpoint {
+		return fileSize - 8
+	}
+	return fileSize
+}
+
 func (aof *AOF) destruct() {
 	aof.state.cas(FileStateClosing, FileStateClosed)
 	var err error
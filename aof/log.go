@@ -0,0 +1,559 @@
+package aof
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/moontrade/kirana/pkg/spinlock"
+	"github.com/moontrade/kirana/pkg/timex"
+	"github.com/moontrade/kirana/reactor"
+)
+
+// LogConfig configures a Log.
+type LogConfig struct {
+	// Geometry of each segment. The Log rolls to a new segment once SizeUpper is
+	// reached.
+	Geometry Geometry
+	// Recovery of the segments when the Log is opened.
+	Recovery Recovery
+	// RollInterval rolls to a new segment at each wall-clock multiple of RollInterval
+	// such as every hour. Disabled if 0.
+	RollInterval time.Duration
+	// MaxBytes deletes the oldest segments while the Log is larger. Disabled if 0.
+	MaxBytes int64
+	// MaxAge deletes segments last written longer than MaxAge ago. Disabled if 0.
+	MaxAge time.Duration
+}
+
+// SegmentInfo is a snapshot of a segment of a Log.
+type SegmentInfo struct {
+	Seq      uint64
+	Name     string
+	Size     int64
+	Finished bool
+	Modified time.Time
+}
+
+type segment struct {
+	seq      uint64
+	name     string
+	size     int64
+	finished bool
+	modified time.Time
+	aof      *AOF
+}
+
+func (s *segment) bytes() int64 {
+	if !s.finished && s.aof != nil {
+		return atomic.LoadInt64(&s.aof.size)
+	}
+	return s.size
+}
+
+// Log is a directory of sequentially numbered AOF segments named "<name>-<seq>.aof".
+// Writes go to the active segment which is finished and replaced by a new one when it
+// reaches Geometry.SizeUpper or a RollInterval boundary. Finished segments are deleted
+// through the Manager once they exceed MaxBytes or MaxAge. A timer rolls and expires
+// segments of an idle Log at the RollInterval boundaries and MaxAge.
+//
+// Like the AOF, a Log has a single producer.
+type Log struct {
+	m        *Manager
+	name     string
+	config   LogConfig
+	mu       sync.Mutex
+	segments []*segment
+	active   *segment
+	rollAt   int64
+	timer    *time.Timer
+	closed   bool
+}
+
+// OpenLog opens the Log name in the directory of the Manager. Segments that were not
+// finished before a crash are recovered and finished except for the last which
+// becomes the active segment.
+func (m *Manager) OpenLog(name string, config LogConfig) (*Log, error) {
+	if len(name) == 0 {
+		return nil, errors.New("empty name")
+	}
+	if config.Recovery.Func == nil {
+		config.Recovery = RecoveryDefault
+	}
+	l := &Log{
+		m:      m,
+		name:   name,
+		config: config,
+	}
+	dir := m.dir
+	if len(dir) == 0 {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		seq, ok := l.parseSeq(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		l.segments = append(l.segments, &segment{
+			seq:      seq,
+			name:     entry.Name(),
+			size:     info.Size(),
+			finished: !hasWritePermission(info.Mode().Perm()),
+			modified: info.ModTime(),
+		})
+	}
+	sort.Slice(l.segments, func(i, j int) bool {
+		return l.segments[i].seq < l.segments[j].seq
+	})
+
+	next := uint64(1)
+	for i, seg := range l.segments {
+		next = seg.seq + 1
+		if seg.finished {
+			continue
+		}
+		if err = l.open(seg); err != nil {
+			return nil, err
+		}
+		if i < len(l.segments)-1 {
+			// Interrupted while rolling.
+			if err = seg.aof.Finish(); err != nil {
+				return nil, err
+			}
+			l.finish(seg)
+		} else {
+			l.active = seg
+		}
+	}
+	if l.active == nil {
+		if err = l.create(next); err != nil {
+			return nil, err
+		}
+	}
+	l.rollAt = l.nextRoll()
+	if err = l.Retain(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) Name() string { return l.name }
+
+func (l *Log) segmentName(seq uint64) string {
+	return fmt.Sprintf("%s-%016d.aof", l.name, seq)
+}
+
+func (l *Log) parseSeq(name string) (uint64, bool) {
+	if !strings.HasPrefix(name, l.name+"-") || !strings.HasSuffix(name, ".aof") {
+		return 0, false
+	}
+	digits := name[len(l.name)+1 : len(name)-len(".aof")]
+	if len(digits) != 16 {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(digits, 10, 64)
+	return seq, err == nil
+}
+
+func (l *Log) open(seg *segment) error {
+	geometry := l.config.Geometry
+	geometry.Create = true
+	aof, err := l.m.Open(seg.name, geometry, l.config.Recovery)
+	if err != nil {
+		return err
+	}
+	seg.aof = aof
+	return nil
+}
+
+// openFinished opens a finished segment for reading.
+func (l *Log) openFinished(seg *segment) (*AOF, error) {
+	if seg.aof != nil {
+		return seg.aof, nil
+	}
+	aof, err := l.m.Open(seg.name, Geometry{}, l.config.Recovery)
+	if err != nil {
+		return nil, err
+	}
+	seg.aof = aof
+	return aof, nil
+}
+
+func (l *Log) create(seq uint64) error {
+	seg := &segment{
+		seq:      seq,
+		name:     l.segmentName(seq),
+		modified: time.Now(),
+	}
+	if err := l.open(seg); err != nil {
+		return err
+	}
+	l.segments = append(l.segments, seg)
+	l.active = seg
+	return nil
+}
+
+func (l *Log) finish(seg *segment) {
+	seg.finished = true
+	seg.size = atomic.LoadInt64(&seg.aof.size)
+	seg.modified = time.Now()
+}
+
+func (l *Log) nextRoll() int64 {
+	if l.config.RollInterval <= 0 {
+		return 0
+	}
+	now := time.Now()
+	boundary := now.Truncate(l.config.RollInterval).Add(l.config.RollInterval)
+	return timex.NanoTime() + int64(boundary.Sub(now))
+}
+
+// Active returns the AOF of the active segment.
+func (l *Log) Active() *AOF {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active.aof
+}
+
+// Segments returns a snapshot of the segments from oldest to newest.
+func (l *Log) Segments() []SegmentInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	infos := make([]SegmentInfo, len(l.segments))
+	for i, seg := range l.segments {
+		infos[i] = SegmentInfo{
+			Seq:      seg.seq,
+			Name:     seg.name,
+			Size:     seg.bytes(),
+			Finished: seg.finished,
+			Modified: seg.modified,
+		}
+	}
+	return infos
+}
+
+// Write writes b to the active segment and rolls to a new segment if it is full or
+// a RollInterval boundary has passed.
+func (l *Log) Write(b []byte) (n int, err error) {
+	err = l.write(func(aof *AOF) (err error) {
+		n, err = aof.Write(b)
+		return err
+	})
+	return n, err
+}
+
+// Append is AOF.Append on the active segment which rolls like Write.
+func (l *Log) Append(reserve int64, appendFn AppendFunc) error {
+	return l.write(func(aof *AOF) error {
+		return aof.Append(reserve, appendFn)
+	})
+}
+
+// write invokes fn with the active segment rolling first if a RollInterval boundary
+// has passed. fn is retried in the next segment if the active segment is full or was
+// rolled by another goroutine in the meantime.
+func (l *Log) write(fn func(aof *AOF) error) error {
+	for {
+		l.mu.Lock()
+		active, rollAt := l.active, l.rollAt
+		l.mu.Unlock()
+		if rollAt > 0 && timex.NanoTime() >= rollAt {
+			if err := l.Roll(); err != nil {
+				return err
+			}
+			continue
+		}
+		err := fn(active.aof)
+		switch {
+		case err == os.ErrClosed && l.rolled(active):
+			continue
+		case err == io.EOF && atomic.LoadInt64(&active.aof.size) > 0:
+			if err = l.Roll(); err != nil {
+				return err
+			}
+			continue
+		}
+		return err
+	}
+}
+
+// rolled reports whether seg is no longer the active segment.
+func (l *Log) rolled(seg *segment) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active != seg
+}
+
+// Roll finishes the active segment and continues in a new one. Tailers of the previous
+// segment continue in the new one once they reach its end. Does nothing if the active
+// segment is empty.
+func (l *Log) Roll() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return os.ErrClosed
+	}
+	l.rollAt = l.nextRoll()
+	prev := l.active
+	if atomic.LoadInt64(&prev.aof.size) == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	// The next segment must exist before Tailers see the end of the previous.
+	if err := l.create(prev.seq + 1); err != nil {
+		l.mu.Unlock()
+		return err
+	}
+	l.mu.Unlock()
+
+	err := prev.aof.Finish()
+	l.mu.Lock()
+	l.finish(prev)
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return l.Retain()
+}
+
+// Retain deletes the oldest finished segments while the Log exceeds MaxBytes or they
+// exceed MaxAge. Tailers reading a deleted segment are closed.
+func (l *Log) Retain() error {
+	var (
+		expired []*segment
+		total   int64
+		now     = time.Now()
+	)
+	l.mu.Lock()
+	for _, seg := range l.segments {
+		total += seg.bytes()
+	}
+	for len(l.segments) > 1 {
+		oldest := l.segments[0]
+		if !(l.config.MaxBytes > 0 && total > l.config.MaxBytes) &&
+			!(l.config.MaxAge > 0 && now.Sub(oldest.modified) > l.config.MaxAge) {
+			break
+		}
+		total -= oldest.bytes()
+		expired = append(expired, oldest)
+		l.segments[0] = nil
+		l.segments = l.segments[1:]
+	}
+	l.schedule()
+	l.mu.Unlock()
+
+	var err error
+	for _, seg := range expired {
+		if e := l.m.Delete(seg.name); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// schedule arms the timer for the next RollInterval boundary or the expiry of the
+// oldest finished segment, whichever comes first. Must be called with l.mu held.
+func (l *Log) schedule() {
+	if l.closed {
+		return
+	}
+	var (
+		next time.Duration
+		ok   bool
+	)
+	if l.rollAt > 0 {
+		next, ok = time.Duration(l.rollAt-timex.NanoTime()), true
+	}
+	if l.config.MaxAge > 0 && len(l.segments) > 1 {
+		expires := time.Until(l.segments[0].modified.Add(l.config.MaxAge))
+		if !ok || expires < next {
+			next, ok = expires, true
+		}
+	}
+	if !ok {
+		return
+	}
+	if next < time.Millisecond {
+		next = time.Millisecond
+	}
+	if l.timer == nil {
+		l.timer = time.AfterFunc(next, l.maintain)
+	} else {
+		l.timer.Reset(next)
+	}
+}
+
+// maintain is invoked by the timer to roll at a RollInterval boundary and expire
+// segments while the Log is idle. Retain re-arms the timer.
+func (l *Log) maintain() {
+	l.mu.Lock()
+	closed, rollAt := l.closed, l.rollAt
+	l.mu.Unlock()
+	if closed {
+		return
+	}
+	if rollAt > 0 && timex.NanoTime() >= rollAt {
+		if err := l.Roll(); err != nil {
+			//logger.WarnErr(err, "log roll failed")
+		}
+	}
+	if err := l.Retain(); err != nil {
+		//logger.WarnErr(err, "log retention failed")
+	}
+}
+
+// next returns the segment after seq or nil if seq is the last.
+func (l *Log) next(seq uint64) (uint64, *AOF, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, seg := range l.segments {
+		if seg.seq > seq {
+			aof, err := l.openFinished(seg)
+			return seg.seq, aof, err
+		}
+	}
+	return 0, nil, nil
+}
+
+// Close closes every segment without finishing the active segment which is recovered
+// when the Log is opened again.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return os.ErrClosed
+	}
+	l.closed = true
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	segments := l.segments
+	l.mu.Unlock()
+	for _, seg := range segments {
+		if seg.aof != nil {
+			_ = seg.aof.Close()
+		}
+	}
+	return nil
+}
+
+// Subscribe reads the Log from the start of its oldest segment. Offsets of each
+// ReadEvent are relative to the segment being read which is returned by
+// LogTailer.Segment. EOF is only set at the end of the last segment once it is
+// finished.
+func (l *Log) Subscribe(c Consumer) (*LogTailer, error) {
+	if c == nil {
+		return nil, errors.New("nil consumer")
+	}
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil, os.ErrClosed
+	}
+	seg := l.segments[0]
+	aof, err := l.openFinished(seg)
+	l.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	t := &LogTailer{log: l, c: c}
+	if err = t.follow(seg.seq, aof); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// LogTailer follows a Consumer across the segments of a Log.
+type LogTailer struct {
+	log    *Log
+	c      Consumer
+	mu     spinlock.Mutex
+	seq    uint64
+	tailer *Tailer
+	closed bool
+}
+
+// Segment returns the sequence of the segment being read.
+func (t *LogTailer) Segment() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.seq
+}
+
+func (t *LogTailer) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return os.ErrClosed
+	}
+	t.closed = true
+	return t.tailer.Close()
+}
+
+func (t *LogTailer) follow(seq uint64, aof *AOF) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return os.ErrClosed
+	}
+	tailer, err := aof.Subscribe(&segmentConsumer{t: t, seq: seq})
+	if err != nil {
+		return err
+	}
+	t.seq = seq
+	t.tailer = tailer
+	return nil
+}
+
+// segmentConsumer forwards the reads of a segment and moves to the next segment once
+// the end of a finished segment is consumed.
+type segmentConsumer struct {
+	t     *LogTailer
+	seq   uint64
+	moved bool
+}
+
+func (c *segmentConsumer) PollRead(event ReadEvent) (int64, error) {
+	var (
+		eof     = event.EOF
+		nextSeq uint64
+		next    *AOF
+		err     error
+	)
+	if eof {
+		if nextSeq, next, err = c.t.log.next(c.seq); err != nil {
+			return event.Begin, err
+		}
+		event.EOF = next == nil
+	}
+	n, err := c.t.c.PollRead(event)
+	if err != nil || next == nil || n < event.End {
+		return n, err
+	}
+	if err = c.t.follow(nextSeq, next); err != nil {
+		return n, os.ErrClosed
+	}
+	c.moved = true
+	return n, reactor.ErrStop
+}
+
+func (c *segmentConsumer) PollReadClosed(reason error) {
+	if !c.moved {
+		c.t.c.PollReadClosed(reason)
+	}
+}
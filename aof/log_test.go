@@ -0,0 +1,171 @@
+package aof
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLogRoll(t *testing.T) {
+	defer func() {
+		os.RemoveAll("testdata")
+	}()
+	m, err := NewManager("testdata", 0755, 0444)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := LogConfig{
+		Geometry: Geometry{SizeUpper: pageSize},
+		MaxBytes: pageSize * 3,
+	}
+	l, err := m.OpenLog("log", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.Repeat([]byte{'a'}, 1000)
+	for i := 0; i < 20; i++ {
+		if _, err = l.Write(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = l.Retain(); err != nil {
+		t.Fatal(err)
+	}
+	segments := l.Segments()
+	if len(segments) < 2 {
+		t.Fatalf("expected the Log to roll, got %d segments", len(segments))
+	}
+	if segments[0].Seq == 1 {
+		t.Fatal("expected the oldest segments to be deleted")
+	}
+	var total int64
+	for i, seg := range segments {
+		total += seg.Size
+		if seg.Finished != (i < len(segments)-1) {
+			t.Fatalf("expected only the active segment to be unfinished: %+v", seg)
+		}
+	}
+	if total > config.MaxBytes {
+		t.Fatalf("expected at most %d bytes, got %d", config.MaxBytes, total)
+	}
+	if _, err = os.Stat("testdata/" + segments[0].Name); err != nil {
+		t.Fatal(err)
+	}
+	last := segments[len(segments)-1]
+	_ = l.Close()
+
+	l, err = m.OpenLog("log", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	reopened := l.Segments()
+	if active := reopened[len(reopened)-1]; active.Seq != last.Seq || active.Size != last.Size {
+		t.Fatalf("expected active segment %d of %d bytes, got %+v", last.Seq, last.Size, active)
+	}
+}
+
+func TestLogIdleRoll(t *testing.T) {
+	defer func() {
+		os.RemoveAll("testdata")
+	}()
+	m, err := NewManager("testdata", 0755, 0444)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := m.OpenLog("idle", LogConfig{
+		RollInterval: time.Millisecond * 100,
+		MaxAge:       time.Millisecond * 150,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if _, err = l.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without further writes the first segment is rolled at the boundary and expired.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		segments := l.Segments()
+		if len(segments) == 1 && segments[0].Seq == 2 && !segments[0].Finished {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the idle Log to roll and expire, got %+v", segments)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if _, err = os.Stat("testdata/idle-0000000000000001.aof"); !os.IsNotExist(err) {
+		t.Fatalf("expected the expired segment to be deleted, got %v", err)
+	}
+}
+
+type logReader struct {
+	mu     sync.Mutex
+	read   int
+	closed chan error
+}
+
+func (r *logReader) PollRead(event ReadEvent) (int64, error) {
+	r.mu.Lock()
+	r.read += len(event.Tail)
+	r.mu.Unlock()
+	return event.End, nil
+}
+
+func (r *logReader) PollReadClosed(reason error) {
+	r.closed <- reason
+}
+
+func (r *logReader) Read() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.read
+}
+
+func TestLogSubscribe(t *testing.T) {
+	defer func() {
+		os.RemoveAll("testdata")
+	}()
+	m, err := NewManager("testdata", 0755, 0444)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := m.OpenLog("log", LogConfig{Geometry: Geometry{SizeUpper: pageSize}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	reader := &logReader{closed: make(chan error, 1)}
+	tailer, err := l.Subscribe(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.Repeat([]byte{'a'}, 1000)
+	const writes = 12
+	for i := 0; i < writes; i++ {
+		if _, err = l.Write(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = l.Active().Wake()
+	deadline := time.Now().Add(5 * time.Second)
+	for reader.Read() < writes*len(buf) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d bytes across segments, got %d", writes*len(buf), reader.Read())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if tailer.Segment() == 1 {
+		t.Fatal("expected the tailer to move to the next segment")
+	}
+	select {
+	case reason := <-reader.closed:
+		t.Fatalf("unexpected close: %v", reason)
+	default:
+	}
+}
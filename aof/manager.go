@@ -7,6 +7,8 @@ import (
 	"github.com/moontrade/kirana/pkg/swap"
 	"github.com/moontrade/kirana/pkg/timex"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
 	. "github.com/moontrade/kirana/pkg/counter"
@@ -60,6 +62,10 @@ type Stats struct {
 	ChmodsDur             TimeCounter
 	ChmodErrors           Counter
 	ChmodErrorsDur        TimeCounter
+	Deletes               Counter
+	DeletesDur            TimeCounter
+	DeleteErrors          Counter
+	DeleteErrorsDur       TimeCounter
 }
 
 var instance *Manager
@@ -85,12 +91,61 @@ type Manager struct {
 	gcList    *swap.SyncSlice[*AOF]
 	flushList *swap.SyncSlice[*AOF]
 	mu        spinlock.Mutex
+	offsets   *Offsets
+	offsetsMu sync.Mutex
 }
 
 func (m *Manager) Stats() Stats {
 	return m.stats
 }
 
+// LiveStats returns the Stats that are being updated. Fields must be read atomically.
+func (m *Manager) LiveStats() *Stats {
+	return &m.stats
+}
+
+// DefaultManager returns the Manager used by Open.
+func DefaultManager() *Manager {
+	return instance
+}
+
+// Files returns a snapshot of the open files.
+func (m *Manager) Files() []*AOF {
+	var files []*AOF
+	m.files.Scan(func(name string, aof *AOF) bool {
+		files = append(files, aof)
+		return true
+	})
+	return files
+}
+
+// Delete closes the file if it is open and removes it from the directory. Tailers of
+// the file are closed.
+func (m *Manager) Delete(name string) error {
+	if aof, ok := m.files.Get(name); ok {
+		_ = aof.Close()
+	}
+	path := name
+	if len(m.dir) > 0 {
+		path = filepath.Join(m.dir, name)
+	}
+	begin := timex.NanoTime()
+	err := os.Remove(path)
+	if err == nil || os.IsNotExist(err) {
+		if e := m.deleteOffsets(name); e != nil && err == nil {
+			err = e
+		}
+	}
+	elapsed := timex.NanoTime() - begin
+	m.stats.Deletes.Incr()
+	m.stats.DeletesDur.Add(elapsed)
+	if err != nil {
+		m.stats.DeleteErrors.Incr()
+		m.stats.DeleteErrorsDur.Add(elapsed)
+	}
+	return err
+}
+
 func NewManager(dir string, writeMode, readMode os.FileMode) (*Manager, error) {
 	if writeMode == 0 {
 		writeMode = 0600
@@ -175,5 +230,10 @@ func (m *Manager) Close() error {
 	m.files.Scan(func(key string, value *AOF) bool {
 		return true
 	})
+	m.offsetsMu.Lock()
+	if m.offsets != nil {
+		_ = m.offsets.Close()
+	}
+	m.offsetsMu.Unlock()
 	return nil
 }
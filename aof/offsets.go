@@ -0,0 +1,278 @@
+package aof
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"github.com/moontrade/kirana/pkg/mmap"
+)
+
+const (
+	// OffsetsFileName is the name of the offsets file in the directory of a Manager.
+	OffsetsFileName = ".offsets"
+	// OffsetSlots is the number of named positions an offsets file holds.
+	OffsetSlots = 4096
+	// offsetSlotSize is the size of a slot of the offset, key length and key.
+	offsetSlotSize = 128
+	// MaxOffsetKey is the maximum length of the file name and consumer name.
+	MaxOffsetKey = offsetSlotSize - 10
+)
+
+var (
+	ErrOffsetsFull = errors.New("offsets file is full")
+	ErrKeyTooLong  = errors.New("file and consumer name too long")
+	ErrNotNamed    = errors.New("tailer is not named")
+	ErrEmptyName   = errors.New("empty consumer name")
+)
+
+// Offsets persists the positions of named consumers in a small mmapped file. Each
+// position is a slot updated atomically in place so a committed offset survives the
+// process crashing. Sync flushes the file to survive the host crashing. The slots of
+// a file's consumers are freed when the Manager deletes the file.
+type Offsets struct {
+	path      string
+	f         *os.File
+	data      mmap.MMap
+	mu        sync.RWMutex
+	positions map[string]*Position
+	free      []int
+}
+
+// Position is the committed offset of a named consumer of an AOF.
+type Position struct {
+	o    *Offsets
+	file string
+	name string
+	// slot is the index of the slot or -1 once freed.
+	slot int
+}
+
+func (p *Position) File() string { return p.file }
+
+func (p *Position) Name() string { return p.name }
+
+// Load returns the committed offset or 0 once the Offsets are closed or the
+// Position freed.
+func (p *Position) Load() int64 {
+	p.o.mu.RLock()
+	defer p.o.mu.RUnlock()
+	if p.o.data == nil || p.slot < 0 {
+		return 0
+	}
+	return loadInt64LE(p.o.slot(p.slot))
+}
+
+// Commit persists offset. Returns os.ErrClosed once the Offsets are closed or the
+// Position freed.
+func (p *Position) Commit(offset int64) error {
+	p.o.mu.RLock()
+	defer p.o.mu.RUnlock()
+	if p.o.data == nil || p.slot < 0 {
+		return os.ErrClosed
+	}
+	storeInt64LE(p.o.slot(p.slot), offset)
+	return nil
+}
+
+func offsetKey(file, name string) string {
+	return file + "\x00" + name
+}
+
+// Offsets returns the offsets file of the Manager opening it on first use.
+func (m *Manager) Offsets() (*Offsets, error) {
+	m.offsetsMu.Lock()
+	defer m.offsetsMu.Unlock()
+	if m.offsets != nil {
+		return m.offsets, nil
+	}
+	o, err := openOffsets(m.offsetsPath(), m.writeMode)
+	if err != nil {
+		return nil, err
+	}
+	m.offsets = o
+	return o, nil
+}
+
+func (m *Manager) offsetsPath() string {
+	if len(m.dir) > 0 {
+		return filepath.Join(m.dir, OffsetsFileName)
+	}
+	return OffsetsFileName
+}
+
+// deleteOffsets frees the positions of file if the Manager has an offsets file.
+func (m *Manager) deleteOffsets(file string) error {
+	m.offsetsMu.Lock()
+	opened := m.offsets != nil
+	m.offsetsMu.Unlock()
+	if !opened {
+		if _, err := os.Stat(m.offsetsPath()); err != nil {
+			return nil
+		}
+	}
+	offsets, err := m.Offsets()
+	if err != nil {
+		return err
+	}
+	return offsets.Delete(file)
+}
+
+func openOffsets(path string, mode os.FileMode) (*Offsets, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, mode)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(OffsetSlots * offsetSlotSize)
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if info.Size() < size {
+		if err = f.Truncate(size); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+	data, err := mmap.MapRegion(f, int(size), mmap.RDWR, 0, 0)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	o := &Offsets{
+		path:      path,
+		f:         f,
+		data:      data,
+		positions: make(map[string]*Position),
+	}
+	// Free slots are popped from the end so the lowest is reused first.
+	for i := OffsetSlots - 1; i >= 0; i-- {
+		slot := o.data[i*offsetSlotSize : (i+1)*offsetSlotSize]
+		n := int(slot[8]) | int(slot[9])<<8
+		if n == 0 || n > MaxOffsetKey {
+			o.free = append(o.free, i)
+			continue
+		}
+		key := string(slot[10 : 10+n])
+		file, name := key, ""
+		for i := 0; i < len(key); i++ {
+			if key[i] == 0 {
+				file, name = key[:i], key[i+1:]
+				break
+			}
+		}
+		o.positions[key] = &Position{o: o, file: file, name: name, slot: i}
+	}
+	return o, nil
+}
+
+func (o *Offsets) slot(i int) unsafe.Pointer {
+	return unsafe.Pointer(&o.data[i*offsetSlotSize])
+}
+
+// Position returns the position of the consumer name of file allocating it at offset 0
+// if it does not exist.
+func (o *Offsets) Position(file, name string) (*Position, error) {
+	if len(name) == 0 {
+		return nil, ErrEmptyName
+	}
+	key := offsetKey(file, name)
+	if len(key) > MaxOffsetKey {
+		return nil, ErrKeyTooLong
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.data == nil {
+		return nil, os.ErrClosed
+	}
+	if p := o.positions[key]; p != nil {
+		return p, nil
+	}
+	if len(o.free) == 0 {
+		return nil, ErrOffsetsFull
+	}
+	i := o.free[len(o.free)-1]
+	o.free = o.free[:len(o.free)-1]
+	slot := o.data[i*offsetSlotSize : (i+1)*offsetSlotSize]
+	storeInt64LE(unsafe.Pointer(&slot[0]), 0)
+	copy(slot[10:], key)
+	// The length is written last so a torn slot is not loaded.
+	slot[9] = byte(len(key) >> 8)
+	slot[8] = byte(len(key))
+	p := &Position{o: o, file: file, name: name, slot: i}
+	o.positions[key] = p
+	return p, nil
+}
+
+// Delete frees the positions of all consumers of file so a file recreated with the
+// same name starts over. Positions already handed out are invalidated.
+func (o *Offsets) Delete(file string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.data == nil {
+		return os.ErrClosed
+	}
+	for key, p := range o.positions {
+		if p.file != file {
+			continue
+		}
+		slot := o.data[p.slot*offsetSlotSize : (p.slot+1)*offsetSlotSize]
+		// The length is cleared first so a torn slot is not loaded.
+		slot[8] = 0
+		slot[9] = 0
+		for i := range slot[:8] {
+			slot[i] = 0
+		}
+		for i := range slot[10:] {
+			slot[10+i] = 0
+		}
+		o.free = append(o.free, p.slot)
+		p.slot = -1
+		delete(o.positions, key)
+	}
+	return nil
+}
+
+// Consumers returns the committed offsets of the named consumers of file.
+func (o *Offsets) Consumers(file string) map[string]int64 {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	consumers := make(map[string]int64)
+	if o.data == nil {
+		return consumers
+	}
+	for _, p := range o.positions {
+		if p.file == file {
+			consumers[p.name] = loadInt64LE(o.slot(p.slot))
+		}
+	}
+	return consumers
+}
+
+// Sync flushes the offsets file to disk.
+func (o *Offsets) Sync() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.data == nil {
+		return os.ErrClosed
+	}
+	return o.data.Flush()
+}
+
+func (o *Offsets) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.data == nil {
+		return os.ErrClosed
+	}
+	_ = o.data.Flush()
+	err := o.data.Unmap()
+	o.data = nil
+	if e := o.f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,189 @@
+package aof
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOffsets(t *testing.T) {
+	defer func() {
+		os.RemoveAll("testdata")
+	}()
+	m, err := NewManager("testdata", 0755, 0444)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offsets, err := m.Offsets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := offsets.Position("db.aof", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := offsets.Position("db.aof", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = offsets.Position("db.aof", ""); err != ErrEmptyName {
+		t.Fatalf("expected ErrEmptyName, got %v", err)
+	}
+	if err = a.Commit(100); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Commit(200); err != nil {
+		t.Fatal(err)
+	}
+	_ = m.Close()
+	if err = a.Commit(300); err != os.ErrClosed {
+		t.Fatalf("expected os.ErrClosed after close, got %v", err)
+	}
+	if a.Load() != 0 {
+		t.Fatalf("expected 0 after close, got %d", a.Load())
+	}
+
+	m, err = NewManager("testdata", 0755, 0444)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	offsets, err = m.Offsets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumers := offsets.Consumers("db.aof")
+	if len(consumers) != 2 || consumers["a"] != 100 || consumers["b"] != 200 {
+		t.Fatalf("expected committed offsets to persist, got %v", consumers)
+	}
+	a, _ = offsets.Position("db.aof", "a")
+	if a.Load() != 100 {
+		t.Fatalf("expected 100, got %d", a.Load())
+	}
+
+	// Deleting the file frees its positions and a recreated file starts over.
+	if err = m.Delete("db.aof"); err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if consumers = offsets.Consumers("db.aof"); len(consumers) != 0 {
+		t.Fatalf("expected no consumers after delete, got %v", consumers)
+	}
+	if err = a.Commit(300); err != os.ErrClosed {
+		t.Fatalf("expected os.ErrClosed after delete, got %v", err)
+	}
+	if p, _ := offsets.Position("db.aof", "a"); p.Load() != 0 {
+		t.Fatalf("expected 0, got %d", p.Load())
+	}
+
+	// Freed slots are reused.
+	for i := 0; ; i++ {
+		if _, err = offsets.Position("full.aof", strconv.Itoa(i)); err == ErrOffsetsFull {
+			if i != OffsetSlots-1 {
+				t.Fatalf("expected %d positions, got %d", OffsetSlots-1, i)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = offsets.Delete("full.aof"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = offsets.Position("db.aof", "b"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type namedReader struct {
+	mu    sync.Mutex
+	begin int64
+	end   int64
+}
+
+func (r *namedReader) PollRead(event ReadEvent) (int64, error) {
+	r.mu.Lock()
+	if r.end == 0 {
+		r.begin = event.Begin
+	}
+	r.end = event.End
+	r.mu.Unlock()
+	return event.End, event.Tailer.Commit(event.End)
+}
+
+func (r *namedReader) PollReadClosed(reason error) {}
+
+func (r *namedReader) Range() (int64, int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.begin, r.end
+}
+
+func TestSubscribeNamed(t *testing.T) {
+	defer func() {
+		os.RemoveAll("testdata")
+	}()
+	m, err := NewManager("testdata", 0755, 0444)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	f, err := m.Open("named.aof", *CreateFile(), RecoveryDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	wait := func(r *namedReader, end int64) int64 {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			begin, e := r.Range()
+			if e == end {
+				return begin
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected to read to %d, got %d", end, e)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	first := &namedReader{}
+	tailer, err := f.SubscribeNamed("first", first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wait(first, 5)
+	_ = tailer.Close()
+
+	if _, err = f.Write([]byte(" world")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The restarted consumer resumes from its committed offset.
+	resumed := &namedReader{}
+	if _, err = f.SubscribeNamed("first", resumed); err != nil {
+		t.Fatal(err)
+	}
+	if begin := wait(resumed, 11); begin != 5 {
+		t.Fatalf("expected to resume at 5, got %d", begin)
+	}
+
+	// Another name progresses independently from the start.
+	second := &namedReader{}
+	if _, err = f.SubscribeNamed("second", second); err != nil {
+		t.Fatal(err)
+	}
+	if begin := wait(second, 11); begin != 0 {
+		t.Fatalf("expected to start at 0, got %d", begin)
+	}
+	offsets, _ := m.Offsets()
+	if consumers := offsets.Consumers("named.aof"); consumers["first"] != 11 || consumers["second"] != 11 {
+		t.Fatalf("unexpected committed offsets %v", consumers)
+	}
+}
@@ -0,0 +1,91 @@
+package record
+
+import (
+	"encoding/binary"
+
+	"github.com/moontrade/kirana/aof"
+	"github.com/moontrade/kirana/reactor"
+)
+
+// RecordFunc receives the payload of the record at offset. The payload references
+// the mapping of the AOF and must be copied to be retained. Returning an error stops
+// the Tailer.
+type RecordFunc func(offset int64, payload []byte) error
+
+// RecordConsumer is an aof.Consumer that delivers whole records to a RecordFunc and
+// returns the offset after the last one to the Tailer.
+type RecordConsumer struct {
+	fn RecordFunc
+	// Closed is called when the Tailer is closed.
+	Closed func(reason error)
+	// Magic is the Checkpoint magic skipped between records.
+	Magic aof.Magic
+	// Limit is the maximum number of records delivered per poll or unlimited if 0.
+	// The Tailer is woken on the next tick to continue.
+	Limit   int
+	records int64
+	// seq is the sequence of the next record. Records before skipSeq or skipOffset
+	// are skipped when starting from an Index entry.
+	seq        uint64
+	skipSeq    uint64
+	skipOffset int64
+}
+
+func NewRecordConsumer(fn RecordFunc) *RecordConsumer {
+	return &RecordConsumer{
+		fn:    fn,
+		Magic: aof.RecoveryDefault.Magic,
+	}
+}
+
+// Records returns the number of records delivered.
+func (c *RecordConsumer) Records() int64 { return c.records }
+
+// Seq returns the sequence of the record being delivered when called from the
+// RecordFunc. Sequences are only known when subscribed from the start of the file or
+// through an Index.
+func (c *RecordConsumer) Seq() uint64 { return c.seq }
+
+func (c *RecordConsumer) PollRead(event aof.ReadEvent) (int64, error) {
+	var (
+		tail  = event.Tail
+		pos   = 0
+		count = 0
+	)
+	for pos < len(tail) {
+		if c.Limit > 0 && count == c.Limit {
+			break
+		}
+		if c.Magic.Checkpoint != 0 && len(tail)-pos >= 8 &&
+			binary.LittleEndian.Uint64(tail[pos:]) == c.Magic.Checkpoint {
+			pos += 8
+			continue
+		}
+		payload, size, err := Decode(tail[pos:])
+		if err != nil {
+			// Records are published whole so anything short is corrupted.
+			return event.Begin + int64(pos), aof.ErrCorrupted
+		}
+		offset := event.Begin + int64(pos)
+		if c.seq >= c.skipSeq && offset >= c.skipOffset {
+			if err = c.fn(offset, payload); err != nil {
+				c.seq++
+				return offset + int64(size), err
+			}
+			c.records++
+		}
+		pos += size
+		count++
+		c.seq++
+	}
+	if event.EOF && pos == len(tail) {
+		return event.End, reactor.ErrStop
+	}
+	return event.Begin + int64(pos), nil
+}
+
+func (c *RecordConsumer) PollReadClosed(reason error) {
+	if c.Closed != nil {
+		c.Closed(reason)
+	}
+}
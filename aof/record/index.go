@@ -0,0 +1,262 @@
+package record
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/moontrade/kirana/aof"
+)
+
+const (
+	// IndexEntrySize is the size of an entry of the sequence, time and offset.
+	IndexEntrySize = 24
+	// DefaultIndexInterval is the minimum number of bytes between entries.
+	DefaultIndexInterval = 4096
+)
+
+var ErrNotIndexed = errors.New("not indexed")
+
+// IndexEntry maps the sequence of a record to its offset and the time it was written.
+type IndexEntry struct {
+	Seq    uint64
+	Time   int64
+	Offset int64
+}
+
+// Index is a sparse index of the records of an AOF persisted in a sidecar AOF named
+// "<name>.idx". An entry is added for the first record at least Interval bytes after
+// the previous entry. Records written without the Index, such as before a crash, are
+// indexed when the Index is opened or on the next indexed write.
+//
+// Entries rebuilt from the records use the time they are rebuilt at which is later
+// than when the records were written. Lookups by time may deliver records written
+// before the time but never skip records written after it.
+type Index struct {
+	data     *aof.AOF
+	file     *aof.AOF
+	magic    aof.Magic
+	interval int64
+	// seq is the sequence of the next record at tail.
+	seq  uint64
+	tail int64
+	last IndexEntry
+	err  error
+}
+
+// OpenIndex opens the Index of the records of data and indexes the records written
+// since its last entry. Entries past the end of data are discarded. Records are
+// assumed to use aof.RecoveryDefault magic.
+func OpenIndex(data *aof.AOF, interval int64) (*Index, error) {
+	if interval <= 0 {
+		interval = DefaultIndexInterval
+	}
+	x := &Index{
+		data:     data,
+		magic:    aof.RecoveryDefault.Magic,
+		interval: interval,
+	}
+	size := data.Size()
+	file, err := data.Manager().Open(data.Name()+".idx", *aof.CreateFile(), aof.Recovery{
+		Func: func(fileSize int64, b []byte, magic aof.Magic) aof.RecoveryResult {
+			return recoverIndex(fileSize, b, size)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	x.file = file
+	x.last = IndexEntry{Offset: -1}
+	if n := x.Len(); n > 0 {
+		x.last = x.Entry(n - 1)
+		x.seq = x.last.Seq
+		x.tail = x.last.Offset
+	}
+	x.catchUp(size)
+	return x, x.err
+}
+
+// recoverIndex truncates the index to the last valid entry before tail.
+func recoverIndex(fileSize int64, b []byte, tail int64) (result aof.RecoveryResult) {
+	result.FileSize = fileSize
+	if fileSize > int64(len(b)) {
+		result.Outcome = aof.Corrupted
+		result.Err = errors.New("fileSize is greater than mapping")
+		return
+	}
+	b = b[0:fileSize]
+	var (
+		pos  = 0
+		prev IndexEntry
+	)
+	for ; len(b)-pos >= IndexEntrySize; pos += IndexEntrySize {
+		entry := decodeEntry(b[pos:])
+		if entry.Time == 0 || entry.Offset >= tail ||
+			(pos > 0 && (entry.Seq <= prev.Seq || entry.Offset <= prev.Offset)) {
+			break
+		}
+		prev = entry
+	}
+	discard := b[pos:]
+	for i := range discard {
+		discard[i] = 0
+	}
+	result.Tail = int64(pos)
+	if pos == 0 {
+		result.Outcome = aof.Empty
+	} else {
+		result.Outcome = aof.Tail
+	}
+	return
+}
+
+func decodeEntry(b []byte) IndexEntry {
+	return IndexEntry{
+		Seq:    binary.LittleEndian.Uint64(b[0:8]),
+		Time:   int64(binary.LittleEndian.Uint64(b[8:16])),
+		Offset: int64(binary.LittleEndian.Uint64(b[16:24])),
+	}
+}
+
+// File returns the sidecar AOF of the Index.
+func (x *Index) File() *aof.AOF { return x.file }
+
+// Err returns the last error writing an entry.
+func (x *Index) Err() error { return x.err }
+
+// Len returns the number of entries.
+func (x *Index) Len() int { return int(x.file.Size() / IndexEntrySize) }
+
+func (x *Index) Entry(i int) IndexEntry {
+	return decodeEntry(x.file.Contents()[i*IndexEntrySize:])
+}
+
+// add indexes the record at offset.
+func (x *Index) add(offset int64, size int) {
+	if offset != x.tail {
+		// Records were written without the Index.
+		x.catchUp(offset)
+	}
+	x.observe(offset, size, 0)
+}
+
+// observe counts the record at offset and adds an entry at time now, or the current
+// time if 0, when it is Interval bytes after the last entry.
+func (x *Index) observe(offset int64, size int, now int64) {
+	if x.last.Offset < 0 || offset-x.last.Offset >= x.interval {
+		if now == 0 {
+			now = time.Now().UnixNano()
+		}
+		if now < x.last.Time {
+			now = x.last.Time
+		}
+		entry := IndexEntry{Seq: x.seq, Time: now, Offset: offset}
+		var b [IndexEntrySize]byte
+		binary.LittleEndian.PutUint64(b[0:8], entry.Seq)
+		binary.LittleEndian.PutUint64(b[8:16], uint64(entry.Time))
+		binary.LittleEndian.PutUint64(b[16:24], uint64(entry.Offset))
+		if _, err := x.file.Write(b[:]); err != nil {
+			x.err = err
+		} else {
+			x.last = entry
+		}
+	}
+	x.seq++
+	x.tail = offset + int64(size)
+}
+
+// catchUp indexes the records from the tail of the Index up to end.
+func (x *Index) catchUp(end int64) {
+	contents := x.data.Contents()
+	if end > int64(len(contents)) {
+		end = int64(len(contents))
+	}
+	now := time.Now().UnixNano()
+	walk(contents[:end], int(x.tail), x.magic.Checkpoint, func(offset, size int) {
+		x.observe(int64(offset), size, now)
+	})
+}
+
+// search returns the last entry for which before is false.
+func (x *Index) search(before func(entry IndexEntry) bool) (IndexEntry, bool) {
+	n := x.Len()
+	i := sort.Search(n, func(i int) bool {
+		return before(x.Entry(i))
+	})
+	if i == 0 {
+		return IndexEntry{}, false
+	}
+	return x.Entry(i - 1), true
+}
+
+// SeekSeq returns the last entry at or before the record seq.
+func (x *Index) SeekSeq(seq uint64) (IndexEntry, bool) {
+	return x.search(func(entry IndexEntry) bool { return entry.Seq > seq })
+}
+
+// SeekOffset returns the last entry at or before offset.
+func (x *Index) SeekOffset(offset int64) (IndexEntry, bool) {
+	return x.search(func(entry IndexEntry) bool { return entry.Offset > offset })
+}
+
+// SeekTime returns the last entry written before t. Every record written at or after
+// t is after it.
+func (x *Index) SeekTime(t time.Time) (IndexEntry, bool) {
+	nanos := t.UnixNano()
+	return x.search(func(entry IndexEntry) bool { return entry.Time >= nanos })
+}
+
+const (
+	fromOffset = iota
+	fromSeq
+	fromTime
+)
+
+// From is where SubscribeFrom starts reading.
+type From struct {
+	kind   int
+	offset int64
+	seq    uint64
+	time   time.Time
+}
+
+// FromOffset starts at the first record at or after offset.
+func FromOffset(offset int64) From { return From{kind: fromOffset, offset: offset} }
+
+// FromSeq starts at the record seq.
+func FromSeq(seq uint64) From { return From{kind: fromSeq, seq: seq} }
+
+// FromTime starts at the last indexed record written before t which may deliver
+// records written before t.
+func FromTime(t time.Time) From { return From{kind: fromTime, time: t} }
+
+// SubscribeFrom subscribes c to the records of the Index's AOF starting at from.
+// Records before from are skipped and Seq of c is the sequence of each record.
+func (x *Index) SubscribeFrom(from From, c *RecordConsumer) (*aof.Tailer, error) {
+	var (
+		entry IndexEntry
+		found bool
+	)
+	switch from.kind {
+	case fromOffset:
+		entry, found = x.SeekOffset(from.offset)
+		c.skipOffset = from.offset
+	case fromSeq:
+		entry, found = x.SeekSeq(from.seq)
+		c.skipSeq = from.seq
+	case fromTime:
+		entry, found = x.SeekTime(from.time)
+	}
+	if !found {
+		// Before the first entry which is the first record.
+		entry = IndexEntry{}
+	}
+	c.seq = entry.Seq
+	return x.data.SubscribeFrom(entry.Offset, c)
+}
+
+// Close closes the sidecar AOF.
+func (x *Index) Close() error {
+	return x.file.Close()
+}
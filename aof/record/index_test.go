@@ -0,0 +1,127 @@
+package record
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/moontrade/kirana/aof"
+	"github.com/moontrade/kirana/reactor"
+)
+
+func init() {
+	reactor.Init(0, reactor.Millis500, 8192*8, 64)
+}
+
+func TestRecoverIndex(t *testing.T) {
+	b := make([]byte, 4096)
+	for i, entry := range []IndexEntry{{0, 1, 0}, {4, 2, 100}, {8, 3, 200}, {12, 4, 300}} {
+		pos := i * IndexEntrySize
+		binary.LittleEndian.PutUint64(b[pos:], entry.Seq)
+		binary.LittleEndian.PutUint64(b[pos+8:], uint64(entry.Time))
+		binary.LittleEndian.PutUint64(b[pos+16:], uint64(entry.Offset))
+	}
+	// The AOF was recovered to 250 so the last entry is discarded.
+	result := recoverIndex(int64(len(b)), b, 250)
+	if result.Outcome != aof.Tail || result.Tail != 3*IndexEntrySize {
+		t.Fatalf("expected 3 entries, got outcome %d tail %d", result.Outcome, result.Tail)
+	}
+	for _, v := range b[result.Tail:] {
+		if v != 0 {
+			t.Fatal("expected discarded entries to be zeroed")
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	defer func() {
+		os.RemoveAll("testdata")
+	}()
+	m, err := aof.NewManager("testdata", 0755, 0444)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := m.Open("indexed.aof", *aof.CreateFile(), Recovery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	index, err := OpenIndex(f, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewRecordWriter(f).WithIndex(index)
+	offsets := make([]int64, 0, 200)
+	for i := 0; i < 100; i++ {
+		offset, err := w.Write([]byte(fmt.Sprintf("record-%03d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		offsets = append(offsets, offset)
+	}
+	// Records written without the Index are indexed on reopen.
+	unindexed := NewRecordWriter(f)
+	for i := 100; i < 200; i++ {
+		offset, err := unindexed.Write([]byte(fmt.Sprintf("record-%03d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		offsets = append(offsets, offset)
+	}
+	_ = index.Close()
+
+	index, err = OpenIndex(f, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+	if last := index.Entry(index.Len() - 1); last.Seq < 190 {
+		t.Fatalf("expected entries up to the last records, got %+v", last)
+	}
+	for _, seq := range []uint64{0, 57, 150, 199} {
+		entry, ok := index.SeekSeq(seq)
+		if !ok || entry.Seq > seq || seq-entry.Seq > uint64(64/Size(10)) {
+			t.Fatalf("unexpected entry %+v for seq %d", entry, seq)
+		}
+		if entry.Offset != offsets[entry.Seq] {
+			t.Fatalf("expected offset %d for seq %d, got %d", offsets[entry.Seq], entry.Seq, entry.Offset)
+		}
+	}
+
+	var (
+		mu   sync.Mutex
+		seqs []uint64
+	)
+	var c *RecordConsumer
+	c = NewRecordConsumer(func(offset int64, payload []byte) error {
+		if want := fmt.Sprintf("record-%03d", c.Seq()); string(payload) != want {
+			return fmt.Errorf("expected %s, got %s", want, payload)
+		}
+		mu.Lock()
+		seqs = append(seqs, c.Seq())
+		mu.Unlock()
+		return nil
+	})
+	if _, err = index.SubscribeFrom(FromSeq(150), c); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(seqs)
+		mu.Unlock()
+		if n == 50 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 50 records from seq 150, got %d", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if seqs[0] != 150 {
+		t.Fatalf("expected first seq 150, got %d", seqs[0])
+	}
+}
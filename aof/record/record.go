@@ -0,0 +1,86 @@
+// Package record frames the contents of an aof.AOF as length-prefixed and CRC32C
+// checksummed records.
+//
+// Each record is a header of the little-endian uint32 length of the payload and the
+// CRC32C of the length and payload followed by the payload. Checkpoint magics written
+// by aof.AOF.Checkpoint between records are skipped.
+package record
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	// HeaderSize is the size of the length and checksum preceding each payload.
+	HeaderSize = 8
+	// MaxSize is the largest payload of a record. It is small enough that a header
+	// can never be mistaken for a Tail or Checkpoint magic.
+	MaxSize = 1 << 30
+)
+
+var (
+	ErrTooLarge = errors.New("record too large")
+	ErrChecksum = errors.New("record checksum mismatch")
+)
+
+var table = crc32.MakeTable(crc32.Castagnoli)
+
+// Size returns the framed size of a payload of n bytes.
+func Size(n int) int { return HeaderSize + n }
+
+func checksum(b []byte, payload []byte) uint32 {
+	// Including the length guarantees a non-zero header for empty payloads.
+	return crc32.Update(crc32.Update(0, table, b[0:4]), table, payload)
+}
+
+// Encode frames payload into b which must have room for Size(len(payload)) bytes.
+// Returns the number of bytes written.
+func Encode(b []byte, payload []byte) int {
+	binary.LittleEndian.PutUint32(b[0:4], uint32(len(payload)))
+	n := copy(b[HeaderSize:], payload)
+	binary.LittleEndian.PutUint32(b[4:8], checksum(b, b[HeaderSize:HeaderSize+n]))
+	return HeaderSize + n
+}
+
+// Decode returns the payload of the record at the start of b and its framed size.
+// Returns io.ErrShortBuffer if b does not hold the entire record.
+func Decode(b []byte) (payload []byte, size int, err error) {
+	if len(b) < HeaderSize {
+		return nil, 0, io.ErrShortBuffer
+	}
+	n := binary.LittleEndian.Uint32(b[0:4])
+	if n > MaxSize {
+		return nil, 0, ErrTooLarge
+	}
+	size = HeaderSize + int(n)
+	if len(b) < size {
+		return nil, 0, io.ErrShortBuffer
+	}
+	payload = b[HeaderSize:size]
+	if binary.LittleEndian.Uint32(b[4:8]) != checksum(b, payload) {
+		return nil, 0, ErrChecksum
+	}
+	return payload, size, nil
+}
+
+// walk calls fn with the offset and size of each record of b from pos, skipping the
+// Checkpoint magic between records, until the end of b or an invalid record. Returns
+// the offset after the last record.
+func walk(b []byte, pos int, checkpoint uint64, fn func(offset, size int)) int {
+	for pos < len(b) {
+		if checkpoint != 0 && len(b)-pos >= 8 && binary.LittleEndian.Uint64(b[pos:]) == checkpoint {
+			pos += 8
+			continue
+		}
+		_, size, err := Decode(b[pos:])
+		if err != nil {
+			break
+		}
+		fn(pos, size)
+		pos += size
+	}
+	return pos
+}
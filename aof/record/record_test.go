@@ -0,0 +1,166 @@
+package record
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/moontrade/kirana/aof"
+	"github.com/moontrade/kirana/reactor"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	for _, payload := range [][]byte{nil, []byte("a"), []byte("hello world")} {
+		b := make([]byte, Size(len(payload)))
+		if n := Encode(b, payload); n != len(b) {
+			t.Fatalf("expected %d bytes, got %d", len(b), n)
+		}
+		if binary.LittleEndian.Uint64(b) == 0 {
+			t.Fatal("header must not be zero")
+		}
+		decoded, size, err := Decode(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != len(b) || string(decoded) != string(payload) {
+			t.Fatalf("expected %q, got %q", payload, decoded)
+		}
+		if _, _, err = Decode(b[:len(b)-1]); err != io.ErrShortBuffer {
+			t.Fatalf("expected io.ErrShortBuffer, got %v", err)
+		}
+		b[len(b)-1]++
+		if len(payload) > 0 {
+			if _, _, err = Decode(b); err != ErrChecksum {
+				t.Fatalf("expected ErrChecksum, got %v", err)
+			}
+		}
+	}
+}
+
+func appendRecords(b []byte, payloads ...string) []byte {
+	for _, payload := range payloads {
+		frame := make([]byte, Size(len(payload)))
+		Encode(frame, []byte(payload))
+		b = append(b, frame...)
+	}
+	return b
+}
+
+func appendMagic(b []byte, magic uint64) []byte {
+	return binary.LittleEndian.AppendUint64(b, magic)
+}
+
+func TestRecover(t *testing.T) {
+	magic := aof.RecoveryDefault.Magic
+	data := appendRecords(nil, "one", "two")
+	data = appendMagic(data, magic.Checkpoint)
+	valid := len(data)
+	data = appendRecords(data, "three")
+	// Partially written record.
+	data = data[:len(data)-2]
+	file := make([]byte, 4096)
+	copy(file, data)
+
+	result := Recover(int64(len(file)), file, magic)
+	if result.Outcome != aof.Tail || result.Tail != int64(valid) {
+		t.Fatalf("expected tail at %d, got outcome %d tail %d", valid, result.Outcome, result.Tail)
+	}
+	if result.Checkpoint != int64(valid-8) {
+		t.Fatalf("expected checkpoint at %d, got %d", valid-8, result.Checkpoint)
+	}
+	for i := valid; i < len(file); i++ {
+		if file[i] != 0 {
+			t.Fatalf("expected partial record to be zeroed at %d", i)
+		}
+	}
+
+	// The Tail magic marks the end.
+	file = make([]byte, 4096)
+	data = appendMagic(appendRecords(nil, "one"), magic.Tail)
+	copy(file, data)
+	result = Recover(int64(len(file)), file, magic)
+	if result.Outcome != aof.Tail || result.Tail != int64(len(data)-8) {
+		t.Fatalf("expected tail at %d, got %d", len(data)-8, result.Tail)
+	}
+	if binary.LittleEndian.Uint64(file[result.Tail:]) != magic.Tail {
+		t.Fatal("expected Tail magic to be kept")
+	}
+
+	if result = Recover(4096, make([]byte, 4096), magic); result.Outcome != aof.Empty {
+		t.Fatalf("expected Empty outcome, got %d", result.Outcome)
+	}
+}
+
+func TestRecordConsumer(t *testing.T) {
+	magic := aof.RecoveryDefault.Magic
+	data := appendRecords(nil, "one", "two")
+	data = appendMagic(data, magic.Checkpoint)
+	data = appendRecords(data, "three")
+
+	var (
+		offsets []int64
+		got     []string
+	)
+	c := NewRecordConsumer(func(offset int64, payload []byte) error {
+		offsets = append(offsets, offset)
+		got = append(got, string(payload))
+		return nil
+	})
+	c.Limit = 2
+	n, err := c.PollRead(aof.ReadEvent{Begin: 0, End: int64(len(data)), Tail: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(Size(3)*2) || len(got) != 2 {
+		t.Fatalf("expected 2 records to be consumed, got %d at %d", len(got), n)
+	}
+	n, err = c.PollRead(aof.ReadEvent{Begin: n, End: int64(len(data)), Tail: data[n:], EOF: true})
+	if err != reactor.ErrStop {
+		t.Fatalf("expected reactor.ErrStop at EOF, got %v", err)
+	}
+	if n != int64(len(data)) {
+		t.Fatalf("expected offset %d, got %d", len(data), n)
+	}
+	if len(got) != 3 || got[2] != "three" || offsets[2] != int64(Size(3)*2+8) {
+		t.Fatalf("unexpected records %v at %v", got, offsets)
+	}
+	if c.Records() != 3 {
+		t.Fatalf("expected 3 records, got %d", c.Records())
+	}
+}
+
+func TestRecordWriter(t *testing.T) {
+	defer func() {
+		os.RemoveAll("testdata")
+	}()
+	m, err := aof.NewManager("testdata", 0755, 0444)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := m.Open("records.aof", *aof.CreateFile(), Recovery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewRecordWriter(f)
+	if _, err = w.Write([]byte("one")); err != nil {
+		t.Fatal(err)
+	}
+	offset, err := w.WriteBatch([][]byte{[]byte("two"), []byte("three")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != int64(Size(3)) {
+		t.Fatalf("expected batch at %d, got %d", Size(3), offset)
+	}
+	_ = f.Close()
+
+	f, err = m.Open("records.aof", *aof.CreateFile(), Recovery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if tail := f.Recovery().Tail; tail != int64(Size(3)*2+Size(5)) {
+		t.Fatalf("expected recovered tail at %d, got %d", Size(3)*2+Size(5), tail)
+	}
+}
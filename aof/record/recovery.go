@@ -0,0 +1,78 @@
+package record
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/moontrade/kirana/aof"
+	"github.com/moontrade/kirana/pkg/util"
+)
+
+// Recovery recovers a file of records with Recover.
+var Recovery = aof.Recovery{
+	Magic: aof.RecoveryDefault.Magic,
+	Func:  Recover,
+}
+
+// Recover is an aof.RecoveryFunc that walks the records from the start of the file
+// and truncates it to the end of the last valid record. Anything after it such as a
+// partially written record is zeroed. The Outcome is Tail unless the file is Empty.
+func Recover(fileSize int64, data []byte, magic aof.Magic) (result aof.RecoveryResult) {
+	defer func() {
+		if e := recover(); e != nil {
+			result.Err = util.PanicToError(e)
+			result.Outcome = aof.Panic
+		}
+	}()
+	result.Magic = magic
+	result.FileSize = fileSize
+	if fileSize > int64(len(data)) {
+		result.Outcome = aof.Corrupted
+		result.Err = errors.New("fileSize is greater than mapping")
+		return
+	}
+	data = data[0:fileSize]
+	pos := 0
+	for len(data)-pos >= 8 {
+		word := binary.LittleEndian.Uint64(data[pos:])
+		if word == 0 {
+			// Headers are never zero.
+			break
+		}
+		if magic.Tail != 0 && word == magic.Tail {
+			break
+		}
+		if magic.Checkpoint != 0 && word == magic.Checkpoint {
+			result.Checkpoint = int64(pos)
+			pos += 8
+			continue
+		}
+		_, size, err := Decode(data[pos:])
+		if err != nil {
+			break
+		}
+		pos += size
+	}
+
+	// Zero everything after the last valid record.
+	end := len(data)
+	for end > pos && data[end-1] == 0 {
+		end--
+	}
+	if end > pos && magic.Tail != 0 && end-pos == 8 &&
+		binary.LittleEndian.Uint64(data[pos:]) == magic.Tail {
+		end = pos
+	}
+	discard := data[pos:end]
+	for i := range discard {
+		discard[i] = 0
+	}
+
+	result.Tail = int64(pos)
+	if pos == 0 {
+		result.Outcome = aof.Empty
+	} else {
+		result.Outcome = aof.Tail
+	}
+	return
+}
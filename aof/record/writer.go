@@ -0,0 +1,102 @@
+package record
+
+import (
+	"github.com/moontrade/kirana/aof"
+)
+
+// RecordWriter appends records to an aof.AOF. Each Write reserves the framed size
+// with AOF.Append so Tailers only ever see whole records. Like the AOF it is single
+// producer and not safe for concurrent use.
+type RecordWriter struct {
+	aof      *aof.AOF
+	payload  []byte
+	batch    [][]byte
+	offset   int64
+	index    *Index
+	appendFn aof.AppendFunc
+	batchFn  aof.AppendFunc
+}
+
+func NewRecordWriter(f *aof.AOF) *RecordWriter {
+	w := &RecordWriter{aof: f}
+	w.appendFn = w.append
+	w.batchFn = w.appendBatch
+	return w
+}
+
+func (w *RecordWriter) AOF() *aof.AOF { return w.aof }
+
+// WithIndex maintains index with each record written.
+func (w *RecordWriter) WithIndex(index *Index) *RecordWriter {
+	w.index = index
+	return w
+}
+
+// Write appends payload as a record and returns its offset.
+func (w *RecordWriter) Write(payload []byte) (int64, error) {
+	if len(payload) > MaxSize {
+		return 0, ErrTooLarge
+	}
+	w.payload = payload
+	err := w.aof.Append(int64(Size(len(payload))), w.appendFn)
+	w.payload = nil
+	if err == nil && w.index != nil {
+		w.index.add(w.offset, Size(len(payload)))
+	}
+	return w.offset, err
+}
+
+// WriteNonBlocking is Write that returns aof.ErrWouldBlock instead of blocking to
+// grow the file.
+func (w *RecordWriter) WriteNonBlocking(payload []byte) (int64, error) {
+	if len(payload) > MaxSize {
+		return 0, ErrTooLarge
+	}
+	w.payload = payload
+	err := w.aof.AppendNonBlocking(int64(Size(len(payload))), w.appendFn)
+	w.payload = nil
+	if err == nil && w.index != nil {
+		w.index.add(w.offset, Size(len(payload)))
+	}
+	return w.offset, err
+}
+
+// WriteBatch appends payloads as consecutive records in a single Append and returns
+// the offset of the first.
+func (w *RecordWriter) WriteBatch(payloads [][]byte) (int64, error) {
+	var reserve int64
+	for _, payload := range payloads {
+		if len(payload) > MaxSize {
+			return 0, ErrTooLarge
+		}
+		reserve += int64(Size(len(payload)))
+	}
+	if reserve == 0 {
+		return 0, nil
+	}
+	w.batch = payloads
+	err := w.aof.Append(reserve, w.batchFn)
+	w.batch = nil
+	if err == nil && w.index != nil {
+		offset := w.offset
+		for _, payload := range payloads {
+			w.index.add(offset, Size(len(payload)))
+			offset += int64(Size(len(payload)))
+		}
+	}
+	return w.offset, err
+}
+
+func (w *RecordWriter) append(event aof.AppendEvent) (int64, error) {
+	w.offset = event.Begin
+	return int64(Encode(event.Tail, w.payload)), nil
+}
+
+func (w *RecordWriter) appendBatch(event aof.AppendEvent) (int64, error) {
+	w.offset = event.Begin
+	n := 0
+	for _, payload := range w.batch {
+		n += Encode(event.Tail[n:], payload)
+	}
+	return int64(n), nil
+}
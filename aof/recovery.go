@@ -42,12 +42,16 @@ type Recovery struct {
 }
 
 type RecoveryResult struct {
-	Magic      Magic
-	Outcome    RecoveryKind
-	FileSize   int64
+	Magic    Magic
+	Outcome  RecoveryKind
+	FileSize int64
+	// Checkpoint is the offset of the last Checkpoint magic or 0 if none was found.
+	// When the Outcome is Checkpoint the file is rolled back to it.
 	Checkpoint int64
-	Tail       int64
-	Err        error
+	// Tail is the offset of the Tail magic or the end of the data when the Outcome
+	// is Corrupted or Checkpoint.
+	Tail int64
+	Err  error
 }
 
 type RecoveryKind int
@@ -56,7 +60,7 @@ const (
 	Empty      RecoveryKind = 0 // The Magic value was found at the tail
 	Corrupted  RecoveryKind = 1 // The Magic value was found at the tail
 	Tail       RecoveryKind = 2 // The Magic value was found at the tail
-	Checkpoint RecoveryKind = 3 // The Magic Checkpoint value was found at the tail
+	Checkpoint RecoveryKind = 3 // The Magic Tail value was not found and the last Magic Checkpoint was
 	Panic      RecoveryKind = 4 // The Magic Checkpoint value was found at the tail
 )
 
@@ -146,7 +150,7 @@ func RecoverWithMagic(fileSize int64, data []byte, magic Magic) (result Recovery
 							magicCheckpointLast := lastByteUint64LE(magic.Checkpoint)
 							// Search for last checkpoint.
 							last--
-							for last > 7 {
+							for last >= 7 {
 								if data[last] != magicCheckpointLast {
 									last--
 									continue
@@ -174,7 +178,7 @@ func RecoverWithMagic(fileSize int64, data []byte, magic Magic) (result Recovery
 						magicCheckpointLast := lastByteUint64LE(magic.Checkpoint)
 						// Search for last checkpoint.
 						last--
-						for last > 7 {
+						for last >= 7 {
 							if data[last] != magicCheckpointLast {
 								last--
 								continue
@@ -183,7 +187,7 @@ func RecoverWithMagic(fileSize int64, data []byte, magic Magic) (result Recovery
 							if d == magic.Checkpoint {
 								result.Outcome = Checkpoint
 								result.Checkpoint = last - 7
-								result.Tail = last + 1
+								result.Tail = tail + 1
 								return
 							}
 							last--
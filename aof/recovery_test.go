@@ -0,0 +1,177 @@
+package aof
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestRecoverWithMagicCheckpoint(t *testing.T) {
+	data := make([]byte, 4096)
+	copy(data, "committed")
+	write64LE(unsafe.Pointer(&data[9]), MagicCheckpoint)
+	copy(data[17:], "uncommitted")
+	write64LE(unsafe.Pointer(&data[28]), MagicTail)
+
+	result := RecoverWithMagic(int64(len(data)), data, RecoveryDefault.Magic)
+	if result.Outcome != Tail {
+		t.Fatalf("expected Tail outcome, got %d", result.Outcome)
+	}
+	if result.Tail != 28 || result.Checkpoint != 9 {
+		t.Fatalf("expected tail 28 and checkpoint 9, got %d and %d", result.Tail, result.Checkpoint)
+	}
+
+	// Crash during a write after the checkpoint.
+	copy(data[28:], "partial!")
+	result = RecoverWithMagic(int64(len(data)), data, RecoveryDefault.Magic)
+	if result.Outcome != Checkpoint {
+		t.Fatalf("expected Checkpoint outcome, got %d", result.Outcome)
+	}
+	if result.Checkpoint != 9 || result.Tail != 36 {
+		t.Fatalf("expected checkpoint 9 and tail 36, got %d and %d", result.Checkpoint, result.Tail)
+	}
+}
+
+func TestCheckpointRollback(t *testing.T) {
+	defer func() {
+		os.RemoveAll("testdata")
+	}()
+	m, err := NewManager("testdata", 0755, 0444)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const name = "checkpoint.aof"
+	f, err := m.Open(name, *CreateFile(), RecoveryDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write([]byte("committed")); err != nil {
+		t.Fatal(err)
+	}
+	offset, err := f.Checkpoint(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 9 || f.LastCheckpoint() != 9 {
+		t.Fatalf("expected checkpoint at 9, got %d", offset)
+	}
+	if _, err = f.Write([]byte("uncommitted")); err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a partial write overwriting the Tail magic.
+	copy(f.data[f.size:], "partial!")
+	_ = f.Close()
+
+	f, err = m.Open(name, *CreateFile(), RecoveryDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	result := f.Recovery()
+	if result.Outcome != Checkpoint || result.Checkpoint != 9 {
+		t.Fatalf("expected rollback to checkpoint 9, got outcome %d checkpoint %d", result.Outcome, result.Checkpoint)
+	}
+	if f.size != 17 || f.LastCheckpoint() != 9 {
+		t.Fatalf("expected size 17, got %d", f.size)
+	}
+	if string(f.data[:9]) != "committed" {
+		t.Fatalf("expected committed data, got %q", f.data[:9])
+	}
+	// The file remains open for appending after the rollback.
+	if _, err = f.Write([]byte("more")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// rawReader copies everything it tails except the Checkpoint magics.
+type rawReader struct {
+	mu          sync.Mutex
+	data        []byte
+	checkpoints []int64
+	end         int64
+}
+
+func (r *rawReader) PollRead(event ReadEvent) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	begin := event.Begin
+	for _, offset := range event.Checkpoints {
+		r.data = append(r.data, event.Tail[begin-event.Begin:offset-event.Begin]...)
+		r.checkpoints = append(r.checkpoints, offset)
+		begin = offset + 8
+	}
+	r.data = append(r.data, event.Tail[begin-event.Begin:]...)
+	r.end = event.End
+	return event.End, nil
+}
+
+func (r *rawReader) PollReadClosed(reason error) {}
+
+func (r *rawReader) wait(t *testing.T, end int64) (string, []int64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r.mu.Lock()
+		if r.end == end {
+			defer r.mu.Unlock()
+			return string(r.data), r.checkpoints
+		}
+		r.mu.Unlock()
+		if time.Now().After(deadline) {
+			t.Fatalf("expected to read to %d", end)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTailCheckpoint(t *testing.T) {
+	defer func() {
+		os.RemoveAll("testdata")
+	}()
+	m, err := NewManager("testdata", 0755, 0444)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const name = "tail-checkpoint.aof"
+	f, err := m.Open(name, *CreateFile(), RecoveryDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write([]byte("committed")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Checkpoint(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write([]byte("more")); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := &rawReader{}
+	if _, err = f.Subscribe(reader); err != nil {
+		t.Fatal(err)
+	}
+	data, checkpoints := reader.wait(t, 21)
+	if data != "committedmore" {
+		t.Fatalf("expected data without the checkpoint magic, got %q", data)
+	}
+	if len(checkpoints) != 1 || checkpoints[0] != 9 {
+		t.Fatalf("expected checkpoint at 9, got %v", checkpoints)
+	}
+	_ = f.Close()
+
+	// The last checkpoint is found again on recovery.
+	f, err = m.Open(name, *CreateFile(), RecoveryDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	reader = &rawReader{}
+	if _, err = f.Subscribe(reader); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ = reader.wait(t, 21); data != "committedmore" {
+		t.Fatalf("expected data without the checkpoint magic after recovery, got %q", data)
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"time"
 )
 
+var ErrInvalidOffset = errors.New("invalid offset")
+
 type TailerState int32
 
 func (t *TailerState) Load() TailerState {
@@ -50,6 +52,11 @@ type ReadEvent struct {
 	FileState  FileState
 	EOF        bool
 	Reason     reactor.PollReason
+
+	// Checkpoints are the offsets of the 8 byte Checkpoint magics within Tail in
+	// ascending order. Only Checkpoints written since the file was opened and the
+	// last one found by recovery are known. The slice must not be modified.
+	Checkpoints []int64
 }
 
 func (re *ReadEvent) CheckGID() bool {
@@ -78,6 +85,7 @@ type Tailer struct {
 	i  int64
 	s  TailerState
 	c  Consumer
+	p  *Position
 	mu spinlock.Mutex
 }
 
@@ -85,6 +93,35 @@ func (t *Tailer) State() TailerState {
 	return t.s.Load()
 }
 
+// Name returns the name of a Tailer subscribed with SubscribeNamed.
+func (t *Tailer) Name() string {
+	if t.p == nil {
+		return ""
+	}
+	return t.p.name
+}
+
+// Commit persists offset as the position of a Tailer subscribed with SubscribeNamed.
+// A restarted consumer resumes from the last committed offset. Returns os.ErrClosed
+// once the Manager is closed.
+func (t *Tailer) Commit(offset int64) error {
+	if t.p == nil {
+		return ErrNotNamed
+	}
+	if offset < 0 || offset > atomic.LoadInt64(&t.a.size) {
+		return ErrInvalidOffset
+	}
+	return t.p.Commit(offset)
+}
+
+// Committed returns the committed offset of a Tailer subscribed with SubscribeNamed.
+func (t *Tailer) Committed() int64 {
+	if t.p == nil {
+		return 0
+	}
+	return t.p.Load()
+}
+
 func (t *Tailer) Close() error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -169,15 +206,16 @@ Begin:
 	}
 
 	n, err := t.pushRead(ReadEvent{
-		Time:      ctx.Time,
-		Tailer:    t,
-		Begin:     t.i,
-		End:       size,
-		Tail:      t.a.data[t.i:size],
-		contents:  t.a.data,
-		FileState: fileState,
-		EOF:       fileState == FileStateEOF,
-		Reason:    ctx.Reason,
+		Time:        ctx.Time,
+		Tailer:      t,
+		Begin:       t.i,
+		End:         size,
+		Tail:        t.a.data[t.i:size],
+		Checkpoints: t.a.checkpointsIn(t.i, size),
+		contents:    t.a.data,
+		FileState:   fileState,
+		EOF:         fileState == FileStateEOF,
+		Reason:      ctx.Reason,
 	})
 	if err != nil {
 		if err != os.ErrClosed && err != reactor.ErrStop {
@@ -224,6 +262,61 @@ func (aof *AOF) Subscribe(
 	return aof.SubscribeInterval(0, c)
 }
 
+// SubscribeFrom is Subscribe starting at offset which must be the start of a write
+// no greater than the current size.
+func (aof *AOF) SubscribeFrom(
+	offset int64,
+	c Consumer,
+) (*Tailer, error) {
+	if offset < 0 || offset > atomic.LoadInt64(&aof.size) {
+		return nil, ErrInvalidOffset
+	}
+	return aof.subscribeFrom(offset, c, nil)
+}
+
+// SubscribeNamed subscribes c as the consumer name which resumes from its committed
+// offset in the Manager's Offsets. The Consumer commits its progress with
+// Tailer.Commit. Consumers with different names progress independently while Tailers
+// of the same name share the position.
+func (aof *AOF) SubscribeNamed(
+	name string,
+	c Consumer,
+) (*Tailer, error) {
+	offsets, err := aof.m.Offsets()
+	if err != nil {
+		return nil, err
+	}
+	position, err := offsets.Position(aof.name, name)
+	if err != nil {
+		return nil, err
+	}
+	offset := position.Load()
+	if size := atomic.LoadInt64(&aof.size); offset > size || offset < 0 {
+		// The file was rolled back past the committed offset.
+		offset = size
+		if err = position.Commit(offset); err != nil {
+			return nil, err
+		}
+	}
+	return aof.subscribeFrom(offset, c, position)
+}
+
+func (aof *AOF) subscribeFrom(offset int64, c Consumer, position *Position) (*Tailer, error) {
+	if c == nil {
+		return nil, errors.New("nil consumer")
+	}
+	tailer := &Tailer{
+		a: aof,
+		i: offset,
+		c: c,
+		p: position,
+	}
+	if _, err := aof.tailers.Spawn(tailer); err != nil {
+		return nil, err
+	}
+	return tailer, nil
+}
+
 func (aof *AOF) SubscribeOn(
 	r *reactor.Reactor,
 	c Consumer,
@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"reflect"
+	"strconv"
+	"unsafe"
+
+	"github.com/moontrade/kirana/aof"
+	"github.com/moontrade/kirana/pkg/histogram"
+	"github.com/moontrade/kirana/reactor"
+)
+
+// ReactorCollector collects the Stats, load and tick duration histogram of every
+// Reactor labelled by reactor name and id.
+func ReactorCollector() Collector {
+	var (
+		snapshot histogram.Snapshot
+		stats    []unsafe.Pointer
+		labels   [][]Label
+	)
+	layout := LayoutOf("kirana_reactor", reflect.TypeOf(reactor.Stats{}))
+	return CollectorFunc(func(w *Writer) {
+		reactors := reactor.Reactors()
+		if len(reactors) == 0 {
+			return
+		}
+		stats = stats[:0]
+		labels = labels[:0]
+		for _, r := range reactors {
+			stats = append(stats, unsafe.Pointer(r.LiveStats()))
+			labels = append(labels, []Label{
+				{Name: "reactor", Value: r.Name()},
+				{Name: "id", Value: strconv.Itoa(r.ID())},
+			})
+		}
+		layout.Write(w, stats, labels)
+
+		w.Family("kirana_reactor_load", TypeGauge, "Smoothed fraction of the tick duration spent processing ticks.")
+		for i, r := range reactors {
+			w.Sample("kirana_reactor_load", r.Load(), labels[i]...)
+		}
+		priorities := [...]struct {
+			name, help string
+			value      func(s reactor.PriorityStats) int64
+		}{
+			{"kirana_reactor_priority_invokes_total", "Invokes flushed per priority.", func(s reactor.PriorityStats) int64 { return s.Invokes }},
+			{"kirana_reactor_priority_wakes_total", "Wakes flushed per priority.", func(s reactor.PriorityStats) int64 { return s.Wakes }},
+			{"kirana_reactor_priority_spawns_total", "Spawns flushed per priority.", func(s reactor.PriorityStats) int64 { return s.Spawns }},
+			{"kirana_reactor_priority_deferred_total", "Flushes that deferred work after reaching the priority weight.", func(s reactor.PriorityStats) int64 { return s.Deferred }},
+		}
+		for _, family := range priorities {
+			w.Family(family.name, TypeCounter, family.help)
+			for i, r := range reactors {
+				for p := reactor.Priority(0); p < reactor.NumPriorities; p++ {
+					w.Sample(family.name, float64(family.value(r.PriorityStats(p))),
+						labels[i][0], labels[i][1], Label{Name: "priority", Value: p.String()})
+				}
+			}
+		}
+		w.Family("kirana_reactor_tick_duration_seconds", TypeHistogram, "Tick processing duration.")
+		for i, r := range reactors {
+			if h := r.TickHistogram(); h != nil {
+				h.Snapshot(&snapshot)
+				w.Histogram("kirana_reactor_tick_duration_seconds", &snapshot, 1e9, labels[i]...)
+			}
+		}
+	})
+}
+
+// TickerCollector collects the jitter histogram and skews of ticker or the global
+// Ticker if nil.
+func TickerCollector(ticker *reactor.Ticker) Collector {
+	var snapshot histogram.Snapshot
+	return CollectorFunc(func(w *Writer) {
+		t := ticker
+		if t == nil {
+			t = reactor.DefaultTicker()
+		}
+		if t == nil {
+			return
+		}
+		t.Jitter().Snapshot(&snapshot)
+		w.Family("kirana_ticker_jitter_seconds", TypeHistogram, "Absolute difference between the scheduled and actual tick time.")
+		w.Histogram("kirana_ticker_jitter_seconds", &snapshot, 1e9)
+		counterSample(w, "kirana_ticker_early_total", "Number of ticks that fired before their scheduled time.", float64(t.Early()))
+		counterSample(w, "kirana_ticker_skews_total", "Number of times the ticker fell behind and skipped ticks.", float64(t.Skews()))
+	})
+}
+
+// BlockingPoolCollector collects the stats of pool or the default BlockingPool if nil.
+func BlockingPoolCollector(pool *reactor.BlockingPool) Collector {
+	return CollectorFunc(func(w *Writer) {
+		p := pool
+		if p == nil {
+			p = reactor.DefaultBlockingPool()
+		}
+		if p == nil {
+			return
+		}
+		s := p.Stats()
+		gauge(w, "kirana_blocking_workers", "Number of blocking workers.", float64(s.Workers))
+		gauge(w, "kirana_blocking_idle_workers", "Number of idle blocking workers.", float64(s.Idle))
+		gauge(w, "kirana_blocking_queued", "Number of queued blocking jobs.", float64(s.Queued))
+		counterSample(w, "kirana_blocking_jobs_total", "Number of blocking jobs submitted.", float64(s.Jobs))
+		counterSample(w, "kirana_blocking_done_total", "Number of blocking jobs completed.", float64(s.Done))
+		counterSample(w, "kirana_blocking_rejected_total", "Number of blocking jobs rejected with a full queue.", float64(s.Rejected))
+		counterSample(w, "kirana_blocking_dropped_total", "Number of queued blocking jobs dropped for newer jobs.", float64(s.Dropped))
+		counterSample(w, "kirana_blocking_timed_out_total", "Number of blocking jobs that expired while queued.", float64(s.TimedOut))
+		counterSample(w, "kirana_blocking_caller_runs_total", "Number of blocking jobs run by the submitter.", float64(s.CallerRuns))
+		counterSample(w, "kirana_blocking_jobs_dur_seconds_total", "Time spent running blocking jobs.", s.JobsDur.Seconds())
+		gauge(w, "kirana_blocking_jobs_dur_min_seconds", "Shortest blocking job.", s.JobsDurMin.Seconds())
+		gauge(w, "kirana_blocking_jobs_dur_max_seconds", "Longest blocking job.", s.JobsDurMax.Seconds())
+	})
+}
+
+// AOFCollector collects the Stats of manager or the default Manager if nil along
+// with the FileStats of each open file labelled by file name.
+func AOFCollector(manager *aof.Manager) Collector {
+	var (
+		stats  []unsafe.Pointer
+		labels [][]Label
+	)
+	managerLayout := LayoutOf("kirana_aof", reflect.TypeOf(aof.Stats{}))
+	fileLayout := LayoutOf("kirana_aof_file", reflect.TypeOf(aof.FileStats{}))
+	return CollectorFunc(func(w *Writer) {
+		m := manager
+		if m == nil {
+			m = aof.DefaultManager()
+		}
+		if m == nil {
+			return
+		}
+		managerLayout.Write(w, []unsafe.Pointer{unsafe.Pointer(m.LiveStats())}, nil)
+
+		files := m.Files()
+		if len(files) == 0 {
+			return
+		}
+		stats = stats[:0]
+		labels = labels[:0]
+		for _, f := range files {
+			stats = append(stats, unsafe.Pointer(f.LiveStats()))
+			labels = append(labels, []Label{{Name: "file", Value: f.Name()}})
+		}
+		fileLayout.Write(w, stats, labels)
+	})
+}
+
+func gauge(w *Writer, name, help string, value float64) {
+	w.Family(name, TypeGauge, help)
+	w.Sample(name, value)
+}
+
+func counterSample(w *Writer, name, help string, value float64) {
+	w.Family(name, TypeCounter, help)
+	w.Sample(name, value)
+}
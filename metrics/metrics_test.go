@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/moontrade/kirana/pkg/counter"
+	"github.com/moontrade/kirana/pkg/histogram"
+)
+
+type testStats struct {
+	Requests    counter.Counter
+	RequestsDur counter.TimeCounter
+	ActiveConns counter.Counter
+	latencyMax  counter.TimeCounter
+	ignored     int64
+}
+
+func TestSnakeCase(t *testing.T) {
+	for in, want := range map[string]string{
+		"OpenFileCount": "open_file_count",
+		"ticksDurMin":   "ticks_dur_min",
+		"HTTPRequests":  "http_requests",
+		"level1Ticks":   "level1_ticks",
+	} {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestStatsLayout(t *testing.T) {
+	stats := &testStats{}
+	stats.Requests.Add(3)
+	stats.RequestsDur.Add(1500000000)
+	stats.ActiveConns.Add(2)
+	stats.latencyMax.Add(250000000)
+
+	layout := LayoutOf("test", reflect.TypeOf(stats))
+	if len(layout.fields) != 4 {
+		t.Fatalf("expected 4 fields, got %d", len(layout.fields))
+	}
+	var w Writer
+	layout.Write(&w, []unsafe.Pointer{unsafe.Pointer(stats)}, [][]Label{{{Name: "name", Value: `a"b`}}})
+	out := string(w.Bytes())
+	for _, want := range []string{
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{name="a\"b"} 3` + "\n",
+		"# TYPE test_requests_dur_seconds_total counter\n",
+		`test_requests_dur_seconds_total{name="a\"b"} 1.5` + "\n",
+		"# TYPE test_active_conns gauge\n",
+		`test_active_conns{name="a\"b"} 2` + "\n",
+		"# TYPE test_latency_max_seconds gauge\n",
+		`test_latency_max_seconds{name="a\"b"} 0.25` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestRegistryHistogram(t *testing.T) {
+	h := histogram.New(1000, 2000)
+	h.Observe(500)
+	h.Observe(1500)
+	h.Observe(5000)
+
+	reg := NewRegistry()
+	reg.Register(CollectorFunc(func(w *Writer) {
+		var s histogram.Snapshot
+		h.Snapshot(&s)
+		w.Family("test_latency_seconds", TypeHistogram, "Latency.")
+		w.Histogram("test_latency_seconds", &s, 1e9, Label{Name: "op", Value: "get"})
+	}))
+	var buf bytes.Buffer
+	if _, err := reg.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "# HELP test_latency_seconds Latency.\n" +
+		"# TYPE test_latency_seconds histogram\n" +
+		`test_latency_seconds_bucket{op="get",le="1e-06"} 1` + "\n" +
+		`test_latency_seconds_bucket{op="get",le="2e-06"} 2` + "\n" +
+		`test_latency_seconds_bucket{op="get",le="+Inf"} 3` + "\n" +
+		`test_latency_seconds_sum{op="get"} 7e-06` + "\n" +
+		`test_latency_seconds_count{op="get"} 3` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Collector writes metric families on each scrape.
+type Collector interface {
+	Collect(w *Writer)
+}
+
+// CollectorFunc adapts a func to a Collector.
+type CollectorFunc func(w *Writer)
+
+func (fn CollectorFunc) Collect(w *Writer) { fn(w) }
+
+// Registry is a set of Collectors exposed over HTTP. Collection only happens on
+// scrape so the hot paths are limited to the atomic counters they already update.
+type Registry struct {
+	collectors []Collector
+	w          Writer
+	mu         sync.Mutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// NewDefaultRegistry creates a Registry with the Reactor, Ticker, BlockingPool and AOF collectors.
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register(ReactorCollector())
+	r.Register(TickerCollector(nil))
+	r.Register(BlockingPoolCollector(nil))
+	r.Register(AOFCollector(nil))
+	return r
+}
+
+func (r *Registry) Register(c Collector) {
+	if c == nil {
+		return
+	}
+	r.mu.Lock()
+	r.collectors = append(r.collectors, c)
+	r.mu.Unlock()
+}
+
+// WriteTo collects all metrics and writes them in the text exposition format.
+func (r *Registry) WriteTo(out io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.w.Reset()
+	for _, c := range r.collectors {
+		c.Collect(&r.w)
+	}
+	n, err := out.Write(r.w.Bytes())
+	return int64(n), err
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+// ListenAndServe serves the Registry at /metrics on addr which should be a local
+// address such as "127.0.0.1:9100".
+func ListenAndServe(addr string, r *Registry) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	return http.ListenAndServe(addr, mux)
+}
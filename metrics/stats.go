@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+	"unsafe"
+
+	"github.com/moontrade/kirana/pkg/counter"
+)
+
+var (
+	counterType     = reflect.TypeOf(counter.Counter(0))
+	timeCounterType = reflect.TypeOf(counter.TimeCounter(0))
+)
+
+// StatsLayout describes the counter.Counter and counter.TimeCounter fields of a
+// stats struct. Fields are read atomically in place so collecting never copies
+// or locks the struct being updated.
+//
+// Fields named with an "Active" prefix or a "Min" or "Max" suffix are gauges and
+// the rest are counters. TimeCounter fields and fields containing "Dur" are
+// nanoseconds and exported in seconds.
+type StatsLayout struct {
+	fields []statsField
+}
+
+type statsField struct {
+	name   string
+	typ    string
+	offset uintptr
+	unit   float64
+}
+
+var layouts sync.Map // map[layoutKey]*StatsLayout
+
+type layoutKey struct {
+	prefix string
+	t      reflect.Type
+}
+
+// LayoutOf returns the cached StatsLayout of struct type t with each metric name
+// prefixed with prefix.
+func LayoutOf(prefix string, t reflect.Type) *StatsLayout {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	key := layoutKey{prefix: prefix, t: t}
+	if l, ok := layouts.Load(key); ok {
+		return l.(*StatsLayout)
+	}
+	l, _ := layouts.LoadOrStore(key, newStatsLayout(prefix, t))
+	return l.(*StatsLayout)
+}
+
+func newStatsLayout(prefix string, t reflect.Type) *StatsLayout {
+	l := &StatsLayout{}
+	if t.Kind() != reflect.Struct {
+		return l
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type != counterType && f.Type != timeCounterType {
+			continue
+		}
+		name := snakeCase(f.Name)
+		field := statsField{
+			name:   prefix + "_" + name,
+			typ:    TypeCounter,
+			offset: f.Offset,
+			unit:   1,
+		}
+		if f.Type == timeCounterType || strings.Contains(f.Name, "Dur") {
+			field.unit = 1e9
+		}
+		if strings.HasPrefix(f.Name, "Active") || strings.HasPrefix(f.Name, "active") ||
+			strings.HasSuffix(f.Name, "Min") || strings.HasSuffix(f.Name, "Max") {
+			field.typ = TypeGauge
+		}
+		if field.unit != 1 {
+			field.name += "_seconds"
+		}
+		if field.typ == TypeCounter {
+			field.name += "_total"
+		}
+		l.fields = append(l.fields, field)
+	}
+	return l
+}
+
+// Write writes a family per field with a sample for each stats struct. stats are
+// pointers to structs of the layout's type and labels[i] are the labels of stats[i].
+func (l *StatsLayout) Write(w *Writer, stats []unsafe.Pointer, labels [][]Label) {
+	for _, f := range l.fields {
+		w.Family(f.name, f.typ, "")
+		for i, p := range stats {
+			if p == nil {
+				continue
+			}
+			v := atomic.LoadInt64((*int64)(unsafe.Add(p, f.offset)))
+			var lbls []Label
+			if i < len(labels) {
+				lbls = labels[i]
+			}
+			w.Sample(f.name, float64(v)/f.unit, lbls...)
+		}
+	}
+}
+
+// snakeCase converts a Go field name such as "OpenFileCount" or "ticksDurMin" to
+// "open_file_count" and "ticks_dur_min".
+func snakeCase(name string) string {
+	b := make([]byte, 0, len(name)+4)
+	for i, c := range name {
+		if unicode.IsUpper(c) {
+			if i > 0 {
+				prev := rune(name[i-1])
+				nextLower := i+1 < len(name) && unicode.IsLower(rune(name[i+1]))
+				if !unicode.IsUpper(prev) || nextLower {
+					b = append(b, '_')
+				}
+			}
+			c = unicode.ToLower(c)
+		}
+		b = append(b, byte(c))
+	}
+	return string(b)
+}
@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/moontrade/kirana/pkg/histogram"
+)
+
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// Label is a metric label pair.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Writer appends metrics in the Prometheus text exposition format. The samples of
+// a family must be written directly after the Family header.
+type Writer struct {
+	buf []byte
+}
+
+func (w *Writer) Reset() { w.buf = w.buf[:0] }
+
+func (w *Writer) Bytes() []byte { return w.buf }
+
+// Family writes the HELP and TYPE header of a metric family.
+func (w *Writer) Family(name, typ, help string) {
+	if len(help) > 0 {
+		w.buf = append(w.buf, "# HELP "...)
+		w.buf = append(w.buf, name...)
+		w.buf = append(w.buf, ' ')
+		w.buf = appendEscaped(w.buf, help, false)
+		w.buf = append(w.buf, '\n')
+	}
+	w.buf = append(w.buf, "# TYPE "...)
+	w.buf = append(w.buf, name...)
+	w.buf = append(w.buf, ' ')
+	w.buf = append(w.buf, typ...)
+	w.buf = append(w.buf, '\n')
+}
+
+// Sample writes a single sample.
+func (w *Writer) Sample(name string, value float64, labels ...Label) {
+	w.sample(name, "", value, labels, "", "")
+}
+
+// Histogram writes the bucket, sum and count samples of s. Bounds and the sum are
+// divided by unit, e.g. 1e9 to convert nanoseconds to seconds. Dividing keeps exact
+// bounds such as 1e-06 which multiplying by the reciprocal does not.
+func (w *Writer) Histogram(name string, s *histogram.Snapshot, unit float64, labels ...Label) {
+	for i, bound := range s.Bounds {
+		w.sample(name, "_bucket", float64(s.Counts[i]), labels, "le", formatFloat(float64(bound)/unit))
+	}
+	w.sample(name, "_bucket", float64(s.Count), labels, "le", "+Inf")
+	w.sample(name, "_sum", float64(s.Sum)/unit, labels, "", "")
+	w.sample(name, "_count", float64(s.Count), labels, "", "")
+}
+
+func (w *Writer) sample(name, suffix string, value float64, labels []Label, extraName, extraValue string) {
+	w.buf = append(w.buf, name...)
+	w.buf = append(w.buf, suffix...)
+	if len(labels) > 0 || len(extraName) > 0 {
+		w.buf = append(w.buf, '{')
+		for i, label := range labels {
+			if i > 0 {
+				w.buf = append(w.buf, ',')
+			}
+			w.buf = appendLabel(w.buf, label.Name, label.Value)
+		}
+		if len(extraName) > 0 {
+			if len(labels) > 0 {
+				w.buf = append(w.buf, ',')
+			}
+			w.buf = appendLabel(w.buf, extraName, extraValue)
+		}
+		w.buf = append(w.buf, '}')
+	}
+	w.buf = append(w.buf, ' ')
+	w.buf = append(w.buf, formatFloat(value)...)
+	w.buf = append(w.buf, '\n')
+}
+
+func appendLabel(b []byte, name, value string) []byte {
+	b = append(b, name...)
+	b = append(b, '=', '"')
+	b = appendEscaped(b, value, true)
+	return append(b, '"')
+}
+
+func appendEscaped(b []byte, s string, quote bool) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			b = append(b, '\\', '\\')
+		case '\n':
+			b = append(b, '\\', 'n')
+		case '"':
+			if quote {
+				b = append(b, '\\', '"')
+			} else {
+				b = append(b, c)
+			}
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
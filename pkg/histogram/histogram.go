@@ -0,0 +1,154 @@
+package histogram
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Histogram is a lock-free fixed bucket histogram. Observe is wait-free and
+// allocation free making it suitable for hot paths.
+type Histogram struct {
+	bounds []int64
+	counts []int64
+	count  int64
+	sum    int64
+}
+
+// Snapshot is a point-in-time copy of a Histogram. Counts are cumulative with
+// Counts[i] being the number of observations <= Bounds[i]. Count includes the
+// observations greater than the last bound.
+type Snapshot struct {
+	Bounds []int64
+	Counts []int64
+	Count  int64
+	Sum    int64
+}
+
+// New creates a Histogram with the upper bounds which are sorted.
+func New(bounds ...int64) *Histogram {
+	b := make([]int64, len(bounds))
+	copy(b, bounds)
+	sort.Slice(b, func(i, j int) bool { return b[i] < b[j] })
+	return &Histogram{
+		bounds: b,
+		counts: make([]int64, len(b)),
+	}
+}
+
+// Exponential returns count bounds starting at start with each multiplied by factor.
+func Exponential(start int64, factor float64, count int) []int64 {
+	if start < 1 {
+		start = 1
+	}
+	if factor <= 1 {
+		factor = 2
+	}
+	bounds := make([]int64, count)
+	v := float64(start)
+	for i := range bounds {
+		bounds[i] = int64(v)
+		v *= factor
+	}
+	return bounds
+}
+
+// LogLinear returns HDR style bounds from min to max where each power of 2 range
+// is divided into sub linear buckets giving a relative precision of 1/sub.
+func LogLinear(min, max int64, sub int) []int64 {
+	if min < 1 {
+		min = 1
+	}
+	if sub < 1 {
+		sub = 1
+	}
+	// Round min down to a power of 2.
+	base := int64(1)
+	for base*2 <= min {
+		base *= 2
+	}
+	bounds := []int64{base}
+	for base < max && base <= math.MaxInt64/2 {
+		step := base / int64(sub)
+		if step < 1 {
+			step = 1
+		}
+		for v := base + step; v <= base*2; v += step {
+			if v > bounds[len(bounds)-1] {
+				bounds = append(bounds, v)
+			}
+		}
+		base *= 2
+	}
+	return bounds
+}
+
+// Durations returns exponential bounds from 1µs to ~1s suitable for latencies in nanoseconds.
+func Durations() []int64 {
+	return Exponential(int64(time.Microsecond), 2, 21)
+}
+
+// Observe records v.
+func (h *Histogram) Observe(v int64) {
+	// Binary search for the first bound >= v
+	lo, hi := 0, len(h.bounds)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if h.bounds[mid] < v {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo < len(h.counts) {
+		atomic.AddInt64(&h.counts[lo], 1)
+	}
+	atomic.AddInt64(&h.count, 1)
+	atomic.AddInt64(&h.sum, v)
+}
+
+// Count returns the total number of observations.
+func (h *Histogram) Count() int64 {
+	return atomic.LoadInt64(&h.count)
+}
+
+// Sum returns the sum of all observations.
+func (h *Histogram) Sum() int64 {
+	return atomic.LoadInt64(&h.sum)
+}
+
+// Snapshot copies the Histogram into s reusing its slices.
+func (h *Histogram) Snapshot(s *Snapshot) {
+	if cap(s.Bounds) < len(h.bounds) {
+		s.Bounds = make([]int64, len(h.bounds))
+		s.Counts = make([]int64, len(h.bounds))
+	}
+	s.Bounds = s.Bounds[:len(h.bounds)]
+	s.Counts = s.Counts[:len(h.bounds)]
+	copy(s.Bounds, h.bounds)
+	var cumulative int64
+	for i := range h.counts {
+		cumulative += atomic.LoadInt64(&h.counts[i])
+		s.Counts[i] = cumulative
+	}
+	s.Count = atomic.LoadInt64(&h.count)
+	if s.Count < cumulative {
+		s.Count = cumulative
+	}
+	s.Sum = atomic.LoadInt64(&h.sum)
+}
+
+// Quantile returns the upper bound of the bucket containing the q quantile.
+func (s *Snapshot) Quantile(q float64) int64 {
+	if s.Count == 0 || len(s.Bounds) == 0 {
+		return 0
+	}
+	rank := int64(q * float64(s.Count))
+	for i, c := range s.Counts {
+		if c >= rank {
+			return s.Bounds[i]
+		}
+	}
+	return s.Bounds[len(s.Bounds)-1]
+}
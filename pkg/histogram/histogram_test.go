@@ -0,0 +1,37 @@
+package histogram
+
+import "testing"
+
+func TestHistogram(t *testing.T) {
+	h := New(100, 10, 1000)
+	for _, v := range []int64{1, 10, 11, 100, 500, 2000} {
+		h.Observe(v)
+	}
+	var s Snapshot
+	h.Snapshot(&s)
+	if s.Count != 6 || s.Sum != 2622 {
+		t.Fatalf("count=%d sum=%d", s.Count, s.Sum)
+	}
+	want := []int64{2, 4, 5}
+	for i, c := range s.Counts {
+		if c != want[i] {
+			t.Fatalf("bucket %d: expected %d, got %d", s.Bounds[i], want[i], c)
+		}
+	}
+	if q := s.Quantile(0.5); q != 100 {
+		t.Fatalf("expected p50 100, got %d", q)
+	}
+}
+
+func TestLogLinear(t *testing.T) {
+	bounds := LogLinear(100, 1000, 4)
+	want := []int64{64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 448, 512, 640, 768, 896, 1024}
+	if len(bounds) != len(want) {
+		t.Fatalf("expected %v, got %v", want, bounds)
+	}
+	for i := range want {
+		if bounds[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, bounds)
+		}
+	}
+}
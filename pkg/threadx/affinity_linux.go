@@ -0,0 +1,38 @@
+package threadx
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// SetAffinity pins the calling OS thread to cpus. The goroutine must be locked to
+// its thread with runtime.LockOSThread for this to be meaningful.
+func SetAffinity(cpus []int) error {
+	if len(cpus) == 0 {
+		return nil
+	}
+	var set unix.CPUSet
+	set.Zero()
+	for _, cpu := range cpus {
+		if cpu < 0 || cpu >= len(set)*64 {
+			return ErrInvalidCPU
+		}
+		set.Set(cpu)
+	}
+	// pid 0 is the calling thread.
+	return unix.SchedSetaffinity(0, &set)
+}
+
+// Affinity returns the CPUs the calling OS thread may run on.
+func Affinity() ([]int, error) {
+	var set unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &set); err != nil {
+		return nil, err
+	}
+	var cpus []int
+	for cpu := 0; cpu < len(set)*64; cpu++ {
+		if set.IsSet(cpu) {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package threadx
+
+// SetAffinity is only supported on Linux.
+func SetAffinity(cpus []int) error {
+	if len(cpus) == 0 {
+		return nil
+	}
+	return ErrAffinityUnsupported
+}
+
+// Affinity is only supported on Linux.
+func Affinity() ([]int, error) {
+	return nil, ErrAffinityUnsupported
+}
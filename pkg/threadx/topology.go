@@ -0,0 +1,192 @@
+package threadx
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidCPU          = errors.New("invalid cpu")
+	ErrAffinityUnsupported = errors.New("cpu affinity not supported")
+)
+
+// SysfsCPU is the sysfs directory describing the CPU topology on Linux.
+const SysfsCPU = "/sys/devices/system/cpu"
+
+// CPU is a logical CPU (hardware thread).
+type CPU struct {
+	ID      int
+	Core    int
+	Package int
+	Node    int
+	// Siblings are the logical CPUs sharing the physical core including this one.
+	Siblings []int
+	// Isolated is true if the CPU is excluded from the kernel scheduler with isolcpus.
+	Isolated bool
+}
+
+// Topology is the set of online logical CPUs.
+type Topology struct {
+	CPUs []CPU
+}
+
+// ReadTopology reads the topology of the online CPUs from SysfsCPU.
+func ReadTopology() (*Topology, error) {
+	return ReadTopologyFrom(SysfsCPU)
+}
+
+// ReadTopologyFrom reads the topology from a sysfs cpu directory.
+func ReadTopologyFrom(root string) (*Topology, error) {
+	online, err := readList(filepath.Join(root, "online"))
+	if err != nil {
+		return nil, err
+	}
+	isolated, err := readList(filepath.Join(root, "isolated"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	isIsolated := make(map[int]bool, len(isolated))
+	for _, id := range isolated {
+		isIsolated[id] = true
+	}
+
+	t := &Topology{CPUs: make([]CPU, 0, len(online))}
+	for _, id := range online {
+		dir := filepath.Join(root, "cpu"+strconv.Itoa(id))
+		cpu := CPU{ID: id, Core: id, Isolated: isIsolated[id]}
+		if v, err := readInt(filepath.Join(dir, "topology", "core_id")); err == nil {
+			cpu.Core = v
+		}
+		if v, err := readInt(filepath.Join(dir, "topology", "physical_package_id")); err == nil {
+			cpu.Package = v
+		}
+		if cpu.Siblings, err = readList(filepath.Join(dir, "topology", "thread_siblings_list")); err != nil {
+			cpu.Siblings = []int{id}
+		}
+		if nodes, _ := filepath.Glob(filepath.Join(dir, "node[0-9]*")); len(nodes) > 0 {
+			if v, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(nodes[0]), "node")); err == nil {
+				cpu.Node = v
+			}
+		}
+		t.CPUs = append(t.CPUs, cpu)
+	}
+	return t, nil
+}
+
+// PhysicalCores returns the first logical CPU of each physical core ordered by
+// isolated cores first and then by NUMA node and CPU id.
+func (t *Topology) PhysicalCores() []int {
+	var cores []CPU
+	for _, cpu := range t.CPUs {
+		if len(cpu.Siblings) > 0 && cpu.Siblings[0] != cpu.ID {
+			continue
+		}
+		cores = append(cores, cpu)
+	}
+	sort.SliceStable(cores, func(i, j int) bool {
+		a, b := cores[i], cores[j]
+		if a.Isolated != b.Isolated {
+			return a.Isolated
+		}
+		if a.Node != b.Node {
+			return a.Node < b.Node
+		}
+		return a.ID < b.ID
+	})
+	ids := make([]int, len(cores))
+	for i, cpu := range cores {
+		ids[i] = cpu.ID
+	}
+	return ids
+}
+
+// CoreMap assigns n CPUs each on a distinct physical core preferring isolated cores.
+// The core of CPU 0 is used last since it handles most of the housekeeping interrupts.
+// When there are fewer physical cores than n the cores are reused round-robin.
+func (t *Topology) CoreMap(n int) []int {
+	cores := t.PhysicalCores()
+	if n <= 0 || len(cores) == 0 {
+		return nil
+	}
+	if len(cores) > 1 {
+		for i, id := range cores {
+			if id == 0 && !t.isolated(id) {
+				cores = append(append(cores[:i:i], cores[i+1:]...), id)
+				break
+			}
+		}
+	}
+	m := make([]int, n)
+	for i := range m {
+		m[i] = cores[i%len(cores)]
+	}
+	return m
+}
+
+// Siblings returns the logical CPUs sharing the physical core of cpu.
+func (t *Topology) Siblings(cpu int) []int {
+	for _, c := range t.CPUs {
+		if c.ID == cpu {
+			return c.Siblings
+		}
+	}
+	return nil
+}
+
+func (t *Topology) isolated(cpu int) bool {
+	for _, c := range t.CPUs {
+		if c.ID == cpu {
+			return c.Isolated
+		}
+	}
+	return false
+}
+
+func readInt(path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+func readList(path string) ([]int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseList(string(b))
+}
+
+// ParseList parses a kernel cpu list such as "0-3,8,10-11".
+func ParseList(s string) ([]int, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return nil, nil
+	}
+	var ids []int
+	for _, part := range strings.Split(s, ",") {
+		lo, hi, found := strings.Cut(part, "-")
+		from, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, err
+		}
+		to := from
+		if found {
+			if to, err = strconv.Atoi(hi); err != nil {
+				return nil, err
+			}
+		}
+		if to < from {
+			return nil, ErrInvalidCPU
+		}
+		for id := from; id <= to; id++ {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
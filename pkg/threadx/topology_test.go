@@ -0,0 +1,73 @@
+package threadx
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParseList(t *testing.T) {
+	ids, err := ParseList("0-3,8,10-11\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{0, 1, 2, 3, 8, 10, 11}; !reflect.DeepEqual(ids, want) {
+		t.Fatal("expected", want, "got", ids)
+	}
+	if _, err = ParseList("3-1"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestReadTopology(t *testing.T) {
+	// 3 cores with 2 threads each. Core 2 is isolated and on NUMA node 1.
+	root := t.TempDir()
+	write := func(path, value string) {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("online", "0-5")
+	write("isolated", "2,5")
+	for cpu := 0; cpu < 6; cpu++ {
+		dir := "cpu" + strconv.Itoa(cpu)
+		core := cpu % 3
+		write(filepath.Join(dir, "topology", "core_id"), strconv.Itoa(core))
+		write(filepath.Join(dir, "topology", "physical_package_id"), "0")
+		write(filepath.Join(dir, "topology", "thread_siblings_list"),
+			strconv.Itoa(core)+","+strconv.Itoa(core+3))
+		node := "node0"
+		if core == 2 {
+			node = "node1"
+		}
+		if err := os.MkdirAll(filepath.Join(root, dir, node), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	topo, err := ReadTopologyFrom(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(topo.CPUs) != 6 {
+		t.Fatal("expected 6 cpus, got", len(topo.CPUs))
+	}
+	if cpu := topo.CPUs[5]; cpu.Core != 2 || cpu.Node != 1 || !cpu.Isolated {
+		t.Fatalf("unexpected cpu %+v", cpu)
+	}
+	if want := []int{2, 0, 1}; !reflect.DeepEqual(topo.PhysicalCores(), want) {
+		t.Fatal("expected", want, "got", topo.PhysicalCores())
+	}
+	if want := []int{2, 1, 0, 2}; !reflect.DeepEqual(topo.CoreMap(4), want) {
+		t.Fatal("expected", want, "got", topo.CoreMap(4))
+	}
+	if want := []int{1, 4}; !reflect.DeepEqual(topo.Siblings(4), want) {
+		t.Fatal("expected", want, "got", topo.Siblings(4))
+	}
+}
@@ -0,0 +1,78 @@
+package reactor
+
+import (
+	"runtime"
+	"sync/atomic"
+
+	"github.com/moontrade/kirana/pkg/pmath"
+	"github.com/moontrade/kirana/pkg/threadx"
+)
+
+// Affinity is the CPU placement of the Reactors, the global Ticker and the
+// BlockingPool workers. Reactor i is pinned to Reactors[i%len(Reactors)]. An
+// empty set leaves the threads unpinned.
+type Affinity struct {
+	Reactors []int
+	Ticker   []int
+	Blocking []int
+}
+
+// AutoAffinity maps each of numLoops Reactors to its own physical core using the
+// sysfs CPU topology preferring isolated cores. The Ticker and BlockingPool share
+// the remaining CPUs that aren't siblings of a Reactor core.
+func AutoAffinity(numLoops int) (Affinity, error) {
+	topology, err := threadx.ReadTopology()
+	if err != nil {
+		return Affinity{}, err
+	}
+	return affinityOf(topology, numLoops), nil
+}
+
+func affinityOf(topology *threadx.Topology, numLoops int) Affinity {
+	numLoops = numReactors(numLoops)
+	affinity := Affinity{Reactors: topology.CoreMap(numLoops)}
+	used := make(map[int]bool)
+	for _, cpu := range affinity.Reactors {
+		used[cpu] = true
+		for _, sibling := range topology.Siblings(cpu) {
+			used[sibling] = true
+		}
+	}
+	var rest []int
+	for _, cpu := range topology.CPUs {
+		if !used[cpu.ID] && !cpu.Isolated {
+			rest = append(rest, cpu.ID)
+		}
+	}
+	affinity.Ticker = rest
+	affinity.Blocking = rest
+	return affinity
+}
+
+func numReactors(numLoops int) int {
+	if numLoops <= 0 {
+		numLoops = runtime.GOMAXPROCS(0)
+	}
+	return pmath.CeilToPowerOf2(numLoops)
+}
+
+// setAffinity pins the calling OS thread to cpus and records the first error. Returns
+// true if the thread was pinned in which case the goroutine must exit without unlocking
+// it so the runtime terminates the thread instead of reusing its restricted CPU mask.
+func setAffinity(cpus []int, dst *atomic.Pointer[error]) bool {
+	if len(cpus) == 0 {
+		return false
+	}
+	if err := threadx.SetAffinity(cpus); err != nil {
+		dst.CompareAndSwap(nil, &err)
+		return false
+	}
+	return true
+}
+
+func loadErr(p *atomic.Pointer[error]) error {
+	if err := p.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
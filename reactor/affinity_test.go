@@ -0,0 +1,71 @@
+package reactor
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/moontrade/kirana/pkg/threadx"
+)
+
+func TestAffinityOf(t *testing.T) {
+	// 4 cores with 2 threads each. Core 3 is isolated.
+	topology := &threadx.Topology{}
+	for cpu := 0; cpu < 8; cpu++ {
+		core := cpu % 4
+		topology.CPUs = append(topology.CPUs, threadx.CPU{
+			ID:       cpu,
+			Core:     core,
+			Siblings: []int{core, core + 4},
+			Isolated: core == 3,
+		})
+	}
+	affinity := affinityOf(topology, 2)
+	if want := []int{3, 1}; !reflect.DeepEqual(affinity.Reactors, want) {
+		t.Fatal("expected reactors on", want, "got", affinity.Reactors)
+	}
+	if want := []int{0, 2, 4, 6}; !reflect.DeepEqual(affinity.Ticker, want) {
+		t.Fatal("expected ticker on", want, "got", affinity.Ticker)
+	}
+}
+
+type affinityTask struct {
+	cpus chan []int
+}
+
+func (a *affinityTask) Poll(ctx Context) error {
+	cpus, _ := threadx.Affinity()
+	a.cpus <- cpus
+	ctx.Stop()
+	return nil
+}
+
+func TestReactorAffinity(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("affinity is only supported on linux")
+	}
+	allowed, err := threadx.Affinity()
+	if err != nil || len(allowed) == 0 {
+		t.Skip("unable to read affinity", err)
+	}
+	cpu := allowed[len(allowed)-1]
+	r, err := NewReactor(Config{Level1Wheel: NewWheel(Millis25), CPU: []int{cpu}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Start()
+	defer r.Close(context.Background())
+
+	task := &affinityTask{cpus: make(chan []int, 1)}
+	if _, err = r.Spawn(task); err != nil {
+		t.Fatal(err)
+	}
+	cpus := <-task.cpus
+	if err = r.AffinityErr(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cpus, []int{cpu}) {
+		t.Fatal("expected reactor pinned to", cpu, "got", cpus)
+	}
+}
@@ -2,240 +2,493 @@ package reactor
 
 import (
 	"context"
+	"errors"
+	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/moontrade/kirana/pkg/counter"
-	"github.com/moontrade/kirana/pkg/fastrand"
 	"github.com/moontrade/kirana/pkg/mpmc"
 	"github.com/moontrade/kirana/pkg/pmath"
-	"github.com/moontrade/kirana/pkg/runtimex"
 	"github.com/moontrade/kirana/pkg/timex"
 	"github.com/moontrade/kirana/pkg/util"
 )
 
+// ErrDropped rejects a job evicted from a full queue by RejectDropOldest.
+var ErrDropped = errors.New("blocking job dropped")
+
+const (
+	blockingOpen    int32 = 0
+	blockingClosing int32 = 1
+	blockingClosed  int32 = 2
+)
+
 func EnqueueBlocking(task func()) bool {
 	return blocking.Enqueue(task)
 }
 
+// RejectionPolicy decides what happens to a job submitted to a BlockingPool with
+// a full queue.
+type RejectionPolicy uint8
+
+const (
+	RejectWait       RejectionPolicy = 0 // RejectWait retries with backoff until the enqueue timeout
+	RejectError      RejectionPolicy = 1 // RejectError fails immediately with ErrQueueFull
+	RejectCallerRuns RejectionPolicy = 2 // RejectCallerRuns runs the job on the submitting goroutine
+	RejectDropOldest RejectionPolicy = 3 // RejectDropOldest evicts the oldest queued job rejecting it with ErrDropped
+)
+
+const (
+	maxBackoff                 = 16
+	DefaultEnqueueTimeout      = time.Second * 10
+	DefaultBlockingIdleTimeout = time.Second * 30
+)
+
+type BlockingConfig struct {
+	// MinWorkers are always running. Defaults to GOMAXPROCS/2.
+	MinWorkers int
+	// MaxWorkers is the max number of workers started when there are queued jobs and
+	// no idle workers. Defaults to MinWorkers.
+	MaxWorkers int
+	QueueSize  int
+	// IdleTimeout is how long a worker above MinWorkers may be idle before exiting.
+	IdleTimeout time.Duration
+	Rejection   RejectionPolicy
+	// EnqueueTimeout is the max wait of RejectWait.
+	EnqueueTimeout time.Duration
+	// CPU is the set of CPUs the workers' OS threads are pinned to.
+	CPU     []int
+	Profile bool
+}
+
 // BlockingPool executes tasks that may block, but *should execute rather quickly <1s.
-// These tasks are forbidden to sleep. Use a worker for those types of tasks. This pool
-// has a fixed number of worker goroutines and tasks are spread among them in round-robin
-// fashion.
+// These tasks are forbidden to sleep. Use a worker for those types of tasks. Workers
+// share a single queue and scale between MinWorkers and MaxWorkers as the queue
+// backs up with no idle workers.
 type BlockingPool struct {
-	started     int64
-	workers     []*blockingWorker
-	workersMask uint32
-	idleCount   counter.Counter
-	ctx         context.Context
-	cancel      context.CancelFunc
-	wg          sync.WaitGroup
-	jobs        counter.Counter
-	done        counter.Counter
-	err         counter.Counter
-	jobsDur     counter.TimeCounter
-	jobsDurMin  counter.Counter
-	jobsDurMax  counter.Counter
-	profile     bool
+	config     BlockingConfig
+	started    int64
+	queue      *mpmc.BoundedWake[blockingJob]
+	signal     chan int64
+	workers    counter.Counter
+	idleCount  counter.Counter
+	closed     int32
+	drainMu    sync.Mutex
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+	jobs       counter.Counter
+	done       counter.Counter
+	err        counter.Counter
+	rejected   counter.Counter
+	dropped    counter.Counter
+	timedOut   counter.Counter
+	callerRuns counter.Counter
+	jobsDur    counter.TimeCounter
+	jobsDurMin counter.Counter
+	jobsDurMax counter.Counter
+	affinity   atomic.Pointer[error]
 }
 
+type blockingJob struct {
+	fn func()
+	// deadline is the time after which the job is rejected with ErrTimeout instead
+	// of being run or 0 for none.
+	deadline int64
+	// reject is invoked when the job is not run.
+	reject func(err error)
+}
+
+var blockingJobPool = sync.Pool{New: func() any { return new(blockingJob) }}
+
 func NewBlockingPool(numWorkers, queueSize int) *BlockingPool {
+	return NewBlockingPoolOn(numWorkers, queueSize, nil)
+}
+
+// NewBlockingPoolOn creates a fixed size BlockingPool with the OS threads of its
+// workers pinned to cpus. Workers share the whole set.
+func NewBlockingPoolOn(numWorkers, queueSize int, cpus []int) *BlockingPool {
 	if numWorkers < 1 {
 		numWorkers = runtime.GOMAXPROCS(0)
 		if numWorkers > 1 {
 			numWorkers /= 2
 		}
 	}
-	queueSize = pmath.CeilToPowerOf2(queueSize)
-	numWorkers = pmath.CeilToPowerOf2(numWorkers)
-	workers := make([]*blockingWorker, numWorkers)
+	return NewBlockingPoolConfig(BlockingConfig{
+		MinWorkers: numWorkers,
+		MaxWorkers: numWorkers,
+		QueueSize:  queueSize,
+		CPU:        cpus,
+	})
+}
+
+func NewBlockingPoolConfig(config BlockingConfig) *BlockingPool {
+	if config.MinWorkers < 1 {
+		config.MinWorkers = runtime.GOMAXPROCS(0)
+		if config.MinWorkers > 1 {
+			config.MinWorkers /= 2
+		}
+	}
+	if config.MaxWorkers < config.MinWorkers {
+		config.MaxWorkers = config.MinWorkers
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = DefaultBlockingIdleTimeout
+	}
+	if config.EnqueueTimeout <= 0 {
+		config.EnqueueTimeout = DefaultEnqueueTimeout
+	}
+	config.QueueSize = pmath.CeilToPowerOf2(config.QueueSize)
+	signal := make(chan int64, config.MaxWorkers)
 	bp := &BlockingPool{
-		started:     timex.NanoTime(),
-		workers:     workers,
-		profile:     false,
-		workersMask: uint32(numWorkers - 1),
+		config:  config,
+		started: timex.NanoTime(),
+		queue:   mpmc.NewBoundedWake[blockingJob](int64(config.QueueSize), signal),
+		signal:  signal,
 	}
 	bp.ctx, bp.cancel = context.WithCancel(context.Background())
-	bp.wg.Add(len(workers))
-	for i := 0; i < len(workers); i++ {
-		worker := &blockingWorker{
-			pool:    bp,
-			started: bp.started,
-			queue:   mpmc.NewBoundedWake[func()](int64(queueSize), nil),
-			wg:      sync.WaitGroup{},
-		}
-		worker.ctx, worker.cancel = context.WithCancel(context.Background())
-		worker.wg.Add(1)
-		workers[i] = worker
-		go worker.run()
-	}
-	bp.wg.Add(1)
+	for i := 0; i < config.MinWorkers; i++ {
+		bp.workers.Incr()
+		bp.startWorker()
+	}
 	return bp
 }
 
+// BlockingStats is a snapshot of BlockingPool statistics.
+type BlockingStats struct {
+	Workers    int
+	Idle       int64
+	Queued     int64
+	Jobs       int64
+	Done       int64
+	Rejected   int64
+	Dropped    int64
+	TimedOut   int64
+	CallerRuns int64
+	JobsDur    time.Duration
+	JobsDurMin time.Duration
+	JobsDurMax time.Duration
+}
+
+// AffinityErr returns the error pinning a worker's thread to the pool's CPUs if any.
+func (b *BlockingPool) AffinityErr() error { return loadErr(&b.affinity) }
+
+// Stats returns a snapshot of the pool statistics. Job durations are only
+// collected when profiling.
+func (b *BlockingPool) Stats() BlockingStats {
+	return BlockingStats{
+		Workers:    int(b.workers.Load()),
+		Idle:       b.idleCount.Load(),
+		Queued:     int64(b.queue.Len()),
+		Jobs:       b.jobs.Load(),
+		Done:       b.done.Load(),
+		Rejected:   b.rejected.Load(),
+		Dropped:    b.dropped.Load(),
+		TimedOut:   b.timedOut.Load(),
+		CallerRuns: b.callerRuns.Load(),
+		JobsDur:    time.Duration(b.jobsDur.Load()),
+		JobsDurMin: time.Duration(b.jobsDurMin.Load()),
+		JobsDurMax: time.Duration(b.jobsDurMax.Load()),
+	}
+}
+
 func (b *BlockingPool) Checkpoint() {
 	for b.jobs.Load() > b.done.Load() {
 		time.Sleep(time.Millisecond * 100)
 	}
 }
 
+// Close stops accepting jobs and waits for the workers to drain the queue and exit.
+// Jobs that raced with Close after the workers exited are run on the calling goroutine
+// and jobs enqueued after that are run by their submitter.
 func (b *BlockingPool) Close() error {
-	b.cancel()
-	for _, worker := range b.workers {
-		_ = worker.Close()
+	if !atomic.CompareAndSwapInt32(&b.closed, blockingOpen, blockingClosing) {
+		return os.ErrClosed
 	}
+	b.cancel()
+	b.wg.Wait()
+	b.drainMu.Lock()
+	defer b.drainMu.Unlock()
+	// Marked closed before the drain so a submit either enqueues before it or sees
+	// blockingClosed and drains itself with drainLate.
+	atomic.StoreInt32(&b.closed, blockingClosed)
+	b.drain()
 	return nil
 }
 
-const (
-	maxBackoff            = 16
-	DefaultEnqueueTimeout = time.Second * 10
-)
+func (b *BlockingPool) drain() {
+	for job := b.queue.Dequeue(); job != nil; job = b.queue.Dequeue() {
+		b.run(job)
+	}
+}
+
+// drainLate runs the jobs enqueued after Close drained the queue on the calling
+// goroutine.
+func (b *BlockingPool) drainLate() {
+	if atomic.LoadInt32(&b.closed) != blockingClosed {
+		return
+	}
+	b.drainMu.Lock()
+	defer b.drainMu.Unlock()
+	b.drain()
+}
+
+func (b *BlockingPool) IsClosed() bool {
+	return atomic.LoadInt32(&b.closed) != blockingOpen
+}
 
 func (b *BlockingPool) Enqueue(fn func()) bool {
-	return b.EnqueueTimeout(fn, DefaultEnqueueTimeout)
+	return b.EnqueueTimeout(fn, b.config.EnqueueTimeout)
 }
 
+// EnqueueTimeout submits fn applying the RejectionPolicy with timeout as the max
+// wait of RejectWait.
 func (b *BlockingPool) EnqueueTimeout(fn func(), timeout time.Duration) bool {
-	var (
-		idx    = fastrand.Uint32()
-		worker = b.workers[idx&b.workersMask]
-		fnp    = runtimex.FuncToPointer(fn)
-	)
+	return b.submit(fn, 0, nil, timeout) == nil
+}
 
-	// Try to enqueue on first worker
-	if worker.queue.EnqueueUnsafe(fnp) {
-		return true
-	}
+// Submit submits fn applying the RejectionPolicy.
+func (b *BlockingPool) Submit(fn func()) error {
+	return b.submit(fn, 0, nil, b.config.EnqueueTimeout)
+}
 
-	// Find an available worker
-	count := 1
-	for {
-		idx++
-		worker = b.workers[idx&b.workersMask]
+func (b *BlockingPool) submit(fn func(), deadline int64, reject func(error), timeout time.Duration) error {
+	if fn == nil {
+		return errors.New("nil func")
+	}
+	if b.IsClosed() {
+		return os.ErrClosed
+	}
+	job := blockingJobPool.Get().(*blockingJob)
+	job.fn, job.deadline, job.reject = fn, deadline, reject
+	// Counted before enqueueing so Done never exceeds Jobs.
+	b.jobs.Incr()
+	if b.queue.Enqueue(job) {
+		b.maybeGrow()
+		b.drainLate()
+		return nil
+	}
+	b.maybeGrow()
 
-		if worker.queue.EnqueueUnsafe(fnp) {
-			return true
+	switch b.config.Rejection {
+	case RejectError:
+	case RejectCallerRuns:
+		b.callerRuns.Incr()
+		b.run(job)
+		return nil
+	case RejectDropOldest:
+		for i := 0; i < maxBackoff; i++ {
+			if oldest := b.queue.Dequeue(); oldest != nil {
+				b.dropped.Incr()
+				b.done.Incr()
+				b.release(oldest, ErrDropped)
+			}
+			if b.queue.Enqueue(job) {
+				b.drainLate()
+				return nil
+			}
 		}
-
-		count++
-		if count >= len(b.workers) {
-			break
+	default:
+		if b.enqueueWait(job, timeout) {
+			b.drainLate()
+			return nil
 		}
 	}
+	b.jobs.Decr()
+	b.rejected.Incr()
+	*job = blockingJob{}
+	blockingJobPool.Put(job)
+	return ErrQueueFull
+}
 
-	// All workers are busy and have full queues.
-	// Exponential backoff after each full loop through the workers.
+// tryEnqueue queues fn without applying the RejectionPolicy so it never blocks or runs
+// fn on the caller. Returns false if the pool is closed or full.
+func (b *BlockingPool) tryEnqueue(fn func()) bool {
+	if b.IsClosed() {
+		return false
+	}
+	job := blockingJobPool.Get().(*blockingJob)
+	job.fn = fn
+	b.jobs.Incr()
+	if b.queue.Enqueue(job) {
+		b.maybeGrow()
+		b.drainLate()
+		return true
+	}
+	b.jobs.Decr()
+	b.rejected.Incr()
+	*job = blockingJob{}
+	blockingJobPool.Put(job)
+	return false
+}
+
+// enqueueWait retries with exponential backoff yields until timeout.
+func (b *BlockingPool) enqueueWait(job *blockingJob, timeout time.Duration) bool {
 	var (
 		start   = timex.NanoTime()
 		backoff = 1
 	)
-
-	// Yield
-	runtime.Gosched()
-
 	for {
-		idx++
-		worker = b.workers[idx&b.workersMask]
-		if worker.queue.EnqueueUnsafe(fnp) {
+		for i := 0; i < backoff; i++ {
+			runtime.Gosched()
+		}
+		if b.queue.Enqueue(job) {
 			return true
 		}
+		if b.IsClosed() || time.Duration(timex.NanoTime()-start) >= timeout {
+			return false
+		}
+		if backoff < maxBackoff {
+			backoff <<= 1
+		}
+	}
+}
 
-		count++
-		if count%len(b.workers) == 0 {
-			// Timed out?
-			if time.Duration(timex.NanoTime()-start) >= timeout {
-				return false
-			}
-			// Exponential backoff yield
-			for i := 0; i < backoff; i++ {
-				runtime.Gosched()
-			}
-			if backoff < maxBackoff {
-				backoff <<= 1
-			}
+// maybeGrow starts a worker if there are queued jobs, no idle workers and fewer than
+// MaxWorkers.
+func (b *BlockingPool) maybeGrow() {
+	if b.idleCount.Load() > 0 {
+		select {
+		case b.signal <- 0:
+		default:
+		}
+		return
+	}
+	if b.queue.IsEmpty() || b.IsClosed() {
+		return
+	}
+	for {
+		workers := b.workers.Load()
+		if workers >= int64(b.config.MaxWorkers) {
+			return
+		}
+		if b.workers.Cas(workers, workers+1) {
+			b.startWorker()
+			return
 		}
 	}
 }
 
-type blockingWorker struct {
-	pool       *BlockingPool
-	started    int64
-	queue      *mpmc.BoundedWake[func()]
-	ctx        context.Context
-	cancel     context.CancelFunc
-	wg         sync.WaitGroup
-	jobs       counter.Counter
-	jobsDur    counter.TimeCounter
-	jobsDurMin counter.Counter
-	jobsDurMax counter.Counter
+// shrink retires the calling worker if there are more than MinWorkers.
+func (b *BlockingPool) shrink() bool {
+	for {
+		workers := b.workers.Load()
+		if workers <= int64(b.config.MinWorkers) {
+			return false
+		}
+		if b.workers.Cas(workers, workers-1) {
+			return true
+		}
+	}
 }
 
-func (w *blockingWorker) Close() error {
-	w.cancel()
-	return nil
+func (b *BlockingPool) startWorker() {
+	b.wg.Add(1)
+	go b.worker()
 }
 
-func (w *blockingWorker) run() {
-	defer func() {
-		w.wg.Done()
-		w.pool.wg.Done()
-	}()
+func (b *BlockingPool) worker() {
+	defer b.wg.Done()
+	if len(b.config.CPU) > 0 {
+		runtime.LockOSThread()
+		if !setAffinity(b.config.CPU, &b.affinity) {
+			defer runtime.UnlockOSThread()
+		}
+	}
 	var (
-		queue     = w.queue
-		queueWake = queue.Wake()
-		done      = w.ctx.Done()
-		begin     = timex.NanoTime()
-		elapsed   = begin
+		done  = b.ctx.Done()
+		timer = time.NewTimer(b.config.IdleTimeout)
 	)
-	onTask := func(task func()) {
-		w.jobs.Incr()
-		defer w.pool.done.Incr()
-		if w.pool.profile {
-			begin = timex.NanoTime()
-		}
-		w.invoke(task)
-		if w.pool.profile {
-			elapsed = timex.NanoTime() - begin
-			w.jobsDur.Add(elapsed)
-			if w.jobsDurMin == 0 {
-				w.jobsDurMin.Store(elapsed)
+	defer timer.Stop()
+	for {
+		if job := b.queue.Dequeue(); job != nil {
+			b.run(job)
+			continue
+		}
+		if b.IsClosed() {
+			// Drained
+			b.workers.Decr()
+			return
+		}
+
+		b.idleCount.Incr()
+		// Enqueue signals when it sees an idle worker so recheck after going idle.
+		if !b.queue.IsEmpty() {
+			b.idleCount.Decr()
+			continue
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
 			}
-			if w.jobsDurMax.Load() < elapsed {
-				w.jobsDurMax.Store(elapsed)
+		}
+		timer.Reset(b.config.IdleTimeout)
+		select {
+		case <-b.signal:
+			b.idleCount.Decr()
+		case <-done:
+			b.idleCount.Decr()
+		case <-timer.C:
+			b.idleCount.Decr()
+			if b.shrink() {
+				if !b.queue.IsEmpty() {
+					b.maybeGrow()
+				}
+				return
 			}
 		}
 	}
-Loop:
-	for {
-		fn := queue.DequeueDeref()
-		//n := queue.DequeueManyDeref(math.MaxUint32, onTask)
+}
 
-		if fn == nil {
-			//if n == 0 {
-			w.pool.idleCount.Incr()
-			//timer.Reset(Time.Hour)
-			select {
-			case <-queueWake:
-				w.pool.idleCount.Decr()
-				continue
-			//case <-timer.C:
-			//	continue
-			case <-done:
-				break Loop
-			}
-		} else {
-			onTask(fn)
-			continue
+func (b *BlockingPool) run(job *blockingJob) {
+	defer b.done.Incr()
+	if job.deadline > 0 && timex.NanoTime() > job.deadline {
+		b.timedOut.Incr()
+		b.release(job, ErrTimeout)
+		return
+	}
+	fn := job.fn
+	b.release(job, nil)
+	if !b.config.Profile {
+		b.invoke(fn)
+		return
+	}
+	begin := timex.NanoTime()
+	b.invoke(fn)
+	elapsed := timex.NanoTime() - begin
+	b.jobsDur.Add(elapsed)
+	for {
+		min := b.jobsDurMin.Load()
+		if (min != 0 && min <= elapsed) || b.jobsDurMin.Cas(min, elapsed) {
+			break
+		}
+	}
+	for {
+		max := b.jobsDurMax.Load()
+		if max >= elapsed || b.jobsDurMax.Cas(max, elapsed) {
+			break
 		}
 	}
 }
 
-func (w *blockingWorker) invoke(task func()) {
+// release returns job to the pool rejecting it with err if not nil.
+func (b *BlockingPool) release(job *blockingJob, err error) {
+	reject := job.reject
+	*job = blockingJob{}
+	blockingJobPool.Put(job)
+	if err != nil && reject != nil {
+		reject(err)
+	}
+}
+
+func (b *BlockingPool) invoke(task func()) {
 	defer func() {
 		e := recover()
 		if e != nil {
+			b.err.Incr()
 			err := util.PanicToError(e)
 			_ = err
 			//logger.WarnErr(err, "panic")
@@ -1,11 +1,13 @@
 package reactor
 
 import (
+	"context"
 	"fmt"
 	"github.com/moontrade/kirana/pkg/counter"
 	"github.com/moontrade/kirana/pkg/runtimex"
 	"github.com/moontrade/kirana/pkg/timex"
 	"github.com/panjf2000/ants/v2"
+	"os"
 	"reflect"
 	"runtime"
 	"sync"
@@ -441,3 +443,158 @@ func BenchmarkBlockingPool(b *testing.B) {
 
 	//fmt.Println("workers", len(bp.workers), " wakes", bp.workers[0].WakeCount(), " wake chan full count", bp.queue.WakeChanFullCount())
 }
+
+func TestBlockingPoolElastic(t *testing.T) {
+	bp := NewBlockingPoolConfig(BlockingConfig{
+		MinWorkers:  1,
+		MaxWorkers:  4,
+		QueueSize:   64,
+		IdleTimeout: time.Millisecond * 20,
+	})
+	release := make(chan struct{})
+	started := new(counter.Counter)
+	for i := 0; i < 8; i++ {
+		if err := bp.Submit(func() {
+			started.Incr()
+			<-release
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for started.Load() < 4 {
+		runtime.Gosched()
+	}
+	if workers := bp.Stats().Workers; workers != 4 {
+		t.Fatal("expected 4 workers, got", workers)
+	}
+	close(release)
+	bp.Checkpoint()
+
+	deadline := time.Now().Add(time.Second * 5)
+	for bp.Stats().Workers > 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("workers did not shrink", bp.Stats().Workers)
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+	if err := bp.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBlockingPoolRejection(t *testing.T) {
+	for _, policy := range []RejectionPolicy{RejectError, RejectCallerRuns, RejectDropOldest} {
+		bp := NewBlockingPoolConfig(BlockingConfig{
+			MinWorkers: 1,
+			QueueSize:  32,
+			Rejection:  policy,
+		})
+		release := make(chan struct{})
+		blocked := make(chan struct{})
+		if err := bp.Submit(func() {
+			close(blocked)
+			<-release
+		}); err != nil {
+			t.Fatal(err)
+		}
+		<-blocked
+
+		var (
+			queued = BlockingOn(bp, func() (int, error) { return 1, nil })
+			ran    = new(counter.Counter)
+			err    error
+		)
+		for i := 0; i < 64 && err == nil; i++ {
+			err = bp.Submit(func() { ran.Incr() })
+		}
+		switch policy {
+		case RejectError:
+			if err != ErrQueueFull || bp.Stats().Rejected == 0 {
+				t.Fatal("expected ErrQueueFull, got", err)
+			}
+		case RejectCallerRuns:
+			if err != nil || bp.Stats().CallerRuns == 0 || ran.Load() == 0 {
+				t.Fatal("expected caller runs, got", err, bp.Stats())
+			}
+		case RejectDropOldest:
+			if err != nil || bp.Stats().Dropped == 0 {
+				t.Fatal("expected drops, got", err, bp.Stats())
+			}
+			if _, _, err = queued.Result(); err != ErrDropped {
+				t.Fatal("expected oldest job dropped, got", err)
+			}
+		}
+		close(release)
+		if err = bp.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if s := bp.Stats(); s.Jobs != s.Done || s.Queued != 0 {
+			t.Fatalf("expected queue drained on close %+v", s)
+		}
+		if err = bp.Submit(func() {}); err != os.ErrClosed {
+			t.Fatal("expected os.ErrClosed, got", err)
+		}
+	}
+}
+
+func TestBlockingPoolCloseLateSubmit(t *testing.T) {
+	bp := NewBlockingPoolConfig(BlockingConfig{MinWorkers: 1, QueueSize: 32})
+	if err := bp.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A Submit that passed the IsClosed check before Close enqueues after the drain.
+	ran := new(counter.Counter)
+	job := blockingJobPool.Get().(*blockingJob)
+	job.fn = func() { ran.Incr() }
+	bp.jobs.Incr()
+	if !bp.queue.Enqueue(job) {
+		t.Fatal("enqueue failed")
+	}
+	bp.drainLate()
+	if ran.Load() != 1 {
+		t.Fatal("late job was dropped")
+	}
+	if s := bp.Stats(); s.Jobs != s.Done || s.Queued != 0 {
+		t.Fatalf("expected late job to be run %+v", s)
+	}
+}
+
+func TestBlockingTimeout(t *testing.T) {
+	bp := NewBlockingPoolConfig(BlockingConfig{MinWorkers: 1, QueueSize: 32})
+	defer bp.Close()
+
+	p := BlockingTimeout(bp, time.Millisecond*10, func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	if _, err := p.Wait(context.Background()); err != ErrTimeout {
+		t.Fatal("expected ErrTimeout, got", err)
+	}
+
+	// Expires while queued behind a blocked job and is never run.
+	release := make(chan struct{})
+	if err := bp.Submit(func() { <-release }); err != nil {
+		t.Fatal(err)
+	}
+	ran := false
+	p = BlockingTimeout(bp, time.Millisecond*10, func(ctx context.Context) (int, error) {
+		ran = true
+		return 1, nil
+	})
+	if _, err := p.Wait(context.Background()); err != ErrTimeout {
+		t.Fatal("expected ErrTimeout, got", err)
+	}
+	close(release)
+	bp.Checkpoint()
+	if ran || bp.Stats().TimedOut != 1 {
+		t.Fatal("expected expired job to be skipped", bp.Stats())
+	}
+
+	p = BlockingTimeout(bp, time.Second, func(ctx context.Context) (int, error) {
+		return 2, nil
+	})
+	if v, err := p.Wait(context.Background()); err != nil || v != 2 {
+		t.Fatal("expected 2, got", v, err)
+	}
+}
@@ -0,0 +1,111 @@
+package reactor
+
+import (
+	"time"
+
+	"github.com/moontrade/kirana/logger/slog"
+)
+
+// budgetWarnInterval rate limits the default budget warning of each Reactor.
+const budgetWarnInterval = int64(time.Second)
+
+// BudgetHandler is invoked on the Reactor goroutine after a poll of task took elapsed
+// exceeding Config.PollBudget. It must not block, e.g. log asynchronously.
+type BudgetHandler func(task *Task, reason PollReason, elapsed time.Duration)
+
+// BudgetOverruns is the number of polls that exceeded Config.PollBudget.
+func (t *Task) BudgetOverruns() int64 { return t.overruns }
+
+// Deferrals is the number of wakes deferred to the next tick because the Task was
+// over budget.
+func (t *Task) Deferrals() int64 { return t.deferrals }
+
+// MaxPollDur is the longest poll of the Task when Config.PollBudget is set or the
+// Reactor is profiling.
+func (t *Task) MaxPollDur() time.Duration { return time.Duration(t.maxPollDur) }
+
+// chargePoll counts a poll of task against its budget for the current tick.
+func (r *Reactor) chargePoll(task *Task) {
+	if task.budgetTick != r.currentTick {
+		task.budgetTick = r.currentTick
+		task.tickPolls = 0
+		task.exhausted = false
+	}
+	task.tickPolls++
+	if max := r.config.MaxPollsPerTick; max > 0 && task.tickPolls >= max {
+		task.exhausted = true
+	}
+}
+
+// checkBudget records the duration of a poll and flags task as exhausted for the
+// rest of the tick if it exceeded the budget.
+func (r *Reactor) checkBudget(task *Task, reason PollReason, elapsed int64) {
+	if elapsed > task.maxPollDur {
+		task.maxPollDur = elapsed
+	}
+	budget := int64(r.config.PollBudget)
+	if budget <= 0 || elapsed <= budget {
+		return
+	}
+	task.overruns++
+	task.exhausted = true
+	r.overBudget.Incr()
+	r.overBudgetDur.Add(elapsed - budget)
+	if handler := r.config.BudgetHandler; handler != nil {
+		r.handleBudget(handler, task, reason, time.Duration(elapsed))
+		return
+	}
+	if now := r.now; now-r.budgetWarnAt >= budgetWarnInterval || r.budgetWarnAt == 0 {
+		r.budgetWarnAt = now
+		r.warnBudget(task, reason, time.Duration(elapsed))
+	}
+}
+
+// warnBudget logs an overrun on the global BlockingPool so the Reactor goroutine never
+// blocks on the logger. The warning is dropped if the pool is full.
+func (r *Reactor) warnBudget(task *Task, reason PollReason, elapsed time.Duration) {
+	pool := blocking
+	if pool == nil {
+		return
+	}
+	// Captured here since the Task may be recycled before the warning is logged.
+	var (
+		name     = r.config.Name
+		id       = task.id
+		future   = futureName(task.future)
+		budget   = r.config.PollBudget
+		overruns = task.overruns
+	)
+	pool.tryEnqueue(func() {
+		slog.Warn("poll exceeded budget",
+			"reactor", name,
+			"task", id,
+			"future", future,
+			"reason", reason,
+			"elapsed", elapsed,
+			"budget", budget,
+			"overruns", overruns,
+		)
+	})
+}
+
+func (r *Reactor) handleBudget(handler BudgetHandler, task *Task, reason PollReason, elapsed time.Duration) {
+	defer func() {
+		if e := recover(); e != nil {
+			//logger.Error(util.PanicToError(e), "BudgetHandler panic")
+		}
+	}()
+	handler(task, reason, elapsed)
+}
+
+// deferOverBudget moves the wake of a Task that exhausted its budget in the current
+// tick to the next tick. Returns true if the wake was deferred.
+func (r *Reactor) deferOverBudget(task *Task) bool {
+	if !task.exhausted || task.budgetTick != r.currentTick {
+		return false
+	}
+	task.deferrals++
+	r.budgetDeferred.Incr()
+	r.schedule(task, time.Nanosecond, true)
+	return true
+}
@@ -0,0 +1,80 @@
+package reactor
+
+import (
+	"testing"
+	"time"
+)
+
+type hotTask struct {
+	spin  time.Duration
+	polls int
+}
+
+func (h *hotTask) Poll(ctx Context) error {
+	h.polls++
+	for begin := time.Now(); time.Since(begin) < h.spin; {
+	}
+	return ctx.Task.Wake()
+}
+
+func TestReactorPollBudget(t *testing.T) {
+	clock := NewManualClock(time.Millisecond * 25)
+	var warned []time.Duration
+	r, err := NewReactor(Config{
+		Level1Wheel: NewWheel(Millis25),
+		Clock:       clock,
+		PollBudget:  time.Microsecond * 100,
+		BudgetHandler: func(task *Task, reason PollReason, elapsed time.Duration) {
+			warned = append(warned, elapsed)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hot := &hotTask{spin: time.Millisecond}
+	task, err := r.Spawn(hot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.onWakeMessage(0)
+	for tick := int64(1); tick <= 3; tick++ {
+		clock.Advance(time.Millisecond * 25)
+		r.onWakeMessage(tick)
+	}
+	// A single poll per tick as each wake is deferred to the next tick.
+	if hot.polls != 4 {
+		t.Fatalf("expected 4 polls, got %d", hot.polls)
+	}
+	if task.BudgetOverruns() != 4 || len(warned) != 4 || task.Deferrals() != 4 {
+		t.Fatalf("overruns=%d warned=%d deferrals=%d", task.BudgetOverruns(), len(warned), task.Deferrals())
+	}
+	if task.MaxPollDur() < time.Millisecond || r.overBudget.Load() != 4 {
+		t.Fatalf("max poll %s over budget %d", task.MaxPollDur(), r.overBudget.Load())
+	}
+}
+
+func TestReactorMaxPollsPerTick(t *testing.T) {
+	clock := NewManualClock(time.Millisecond * 25)
+	r, err := NewReactor(Config{
+		Level1Wheel:     NewWheel(Millis25),
+		Clock:           clock,
+		MaxPollsPerTick: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hot := &hotTask{}
+	task, err := r.Spawn(hot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.onWakeMessage(0)
+	if hot.polls != 3 || task.Deferrals() != 1 {
+		t.Fatalf("expected 3 polls and 1 deferral, got %d and %d", hot.polls, task.Deferrals())
+	}
+	clock.Advance(time.Millisecond * 25)
+	r.onWakeMessage(1)
+	if hot.polls != 6 || task.Deferrals() != 2 {
+		t.Fatalf("expected 6 polls and 2 deferrals, got %d and %d", hot.polls, task.Deferrals())
+	}
+}
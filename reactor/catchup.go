@@ -0,0 +1,43 @@
+package reactor
+
+// CatchUpPolicy is how a Reactor handles the ticks it missed while behind.
+type CatchUpPolicy uint8
+
+const (
+	// CatchUpReplay polls interval tasks for every missed tick with ReasonIntervalBehind.
+	CatchUpReplay CatchUpPolicy = 0
+	// CatchUpCoalesce polls each interval task at most once for all the missed ticks
+	// with ReasonIntervalBehind.
+	CatchUpCoalesce CatchUpPolicy = 1
+	// CatchUpSkip skips the interval polls of missed ticks. Wakes scheduled in the
+	// missed ticks are still delivered.
+	CatchUpSkip CatchUpPolicy = 2
+)
+
+// catchup advances the wheels through the ticks after lastTick and before currentTick
+// which is processed normally afterwards.
+func (r *Reactor) catchup(lastTick, currentTick int64) {
+	r.missedTicks.Add(currentTick - 1 - lastTick)
+
+	now := r.nanotime()
+	r.catchingUp = true
+	defer func() {
+		r.catchingUp = false
+	}()
+	for nextTick := lastTick + 1; nextTick < currentTick; nextTick++ {
+		r.tick(nextTick, now)
+	}
+}
+
+// skipInterval returns true if the interval poll of task should be skipped while
+// catching up.
+func (r *Reactor) skipInterval(now int64, task *Task) bool {
+	switch r.config.CatchUp {
+	case CatchUpSkip:
+		return true
+	case CatchUpCoalesce:
+		// All missed ticks share the same time.
+		return task.lastPoll == now && task.polls > 0
+	}
+	return false
+}
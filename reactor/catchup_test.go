@@ -0,0 +1,73 @@
+package reactor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moontrade/kirana/pkg/histogram"
+)
+
+type reasonRecorder struct {
+	reasons map[PollReason]int
+}
+
+func (t *reasonRecorder) Poll(ctx Context) error {
+	t.reasons[ctx.Reason]++
+	return nil
+}
+
+func TestReactorCatchUp(t *testing.T) {
+	for _, tc := range []struct {
+		policy CatchUpPolicy
+		behind int
+	}{
+		{CatchUpReplay, 3},
+		{CatchUpCoalesce, 1},
+		{CatchUpSkip, 0},
+	} {
+		clock := NewManualClock(time.Millisecond * 25)
+		r, err := NewReactor(Config{
+			Level1Wheel: NewWheel(Millis25),
+			Clock:       clock,
+			CatchUp:     tc.policy,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Drive the Reactor from the test goroutine without starting it.
+		task := &reasonRecorder{reasons: make(map[PollReason]int)}
+		if _, err = r.SpawnInterval(task, time.Millisecond*25); err != nil {
+			t.Fatal(err)
+		}
+		r.onWakeMessage(0)
+		r.onWakeMessage(1)
+		if task.reasons[ReasonStart] != 1 || task.reasons[ReasonInterval] != 1 {
+			t.Fatalf("policy %d: unexpected polls %v", tc.policy, task.reasons)
+		}
+		// Ticks 2, 3 and 4 are missed.
+		clock.Advance(time.Millisecond * 100)
+		r.onWakeMessage(5)
+		if task.reasons[ReasonIntervalBehind] != tc.behind {
+			t.Fatalf("policy %d: expected %d behind polls, got %v", tc.policy, tc.behind, task.reasons)
+		}
+		if task.reasons[ReasonInterval] != 2 {
+			t.Fatalf("policy %d: expected 2 interval polls, got %v", tc.policy, task.reasons)
+		}
+		if missed := r.missedTicks.Load(); missed != 3 {
+			t.Fatalf("policy %d: expected 3 missed ticks, got %d", tc.policy, missed)
+		}
+	}
+}
+
+func TestTickerJitter(t *testing.T) {
+	ticker := StartTicker(time.Millisecond)
+	defer ticker.Close()
+	for ticker.Jitter().Count() < 10 {
+		time.Sleep(time.Millisecond)
+	}
+	var s histogram.Snapshot
+	ticker.Jitter().Snapshot(&s)
+	if s.Count < 10 || len(s.Bounds) != len(JitterBounds()) {
+		t.Fatalf("unexpected jitter snapshot count=%d buckets=%d", s.Count, len(s.Bounds))
+	}
+}
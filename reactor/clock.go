@@ -0,0 +1,106 @@
+package reactor
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/moontrade/kirana/pkg/cow"
+)
+
+// Clock is the source of time and ticks of a Reactor. The global Ticker is the
+// default Clock.
+type Clock interface {
+	// Now returns the current monotonic time in nanoseconds.
+	Now() int64
+	// Register a TickListener that sends the tick number to ch every duration.
+	Register(duration time.Duration, owner interface{}, ch chan int64) (*TickListener, error)
+}
+
+// ManualClock is a virtual Clock that only moves when advanced. Each tick is delivered
+// to the registered Reactors which are waited on to process the tick along with all
+// the wakes, invokes and spawns it produced before moving on. This makes wheel slots,
+// intervals and WakeAfter deterministic and instant in tests.
+type ManualClock struct {
+	now        int64
+	tick       time.Duration
+	ticks      int64
+	notifyList cow.Slice[*TickListener]
+	mu         sync.Mutex
+}
+
+// NewManualClock creates a ManualClock with the tick duration which should match the
+// Level1Wheel tick duration of the Reactors using it.
+func NewManualClock(tick time.Duration) *ManualClock {
+	if tick <= 0 {
+		tick = time.Millisecond
+	}
+	return &ManualClock{tick: tick}
+}
+
+func (c *ManualClock) Now() int64 {
+	return atomic.LoadInt64(&c.now)
+}
+
+// TickDur returns the tick duration.
+func (c *ManualClock) TickDur() time.Duration {
+	return c.tick
+}
+
+func (c *ManualClock) Register(duration time.Duration, owner interface{}, ch chan int64) (*TickListener, error) {
+	ln, err := newTickListener(c, duration, owner, ch)
+	if err != nil {
+		return nil, err
+	}
+	ln.blocking = true
+	// Tick 0 is ignored by Reactors.
+	ln.next.Tick = 1
+	c.notifyList.Append(ln)
+	return ln, nil
+}
+
+func (c *ManualClock) remove(tl *TickListener) {
+	c.notifyList.Remove(func(elem *TickListener) bool {
+		return elem == tl
+	})
+}
+
+// Tick advances the clock by a single tick.
+func (c *ManualClock) Tick() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.doTick()
+}
+
+// Advance moves the clock forward by d delivering every tick boundary crossed
+// along the way. Ticks are at multiples of the tick duration.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	target := atomic.LoadInt64(&c.now) + int64(d)
+	for (c.ticks+1)*int64(c.tick) <= target {
+		c.doTick()
+	}
+	atomic.StoreInt64(&c.now, target)
+}
+
+func (c *ManualClock) doTick() {
+	msg := Tick{
+		Tick:      c.ticks,
+		Dur:       c.tick,
+		Precision: c.tick,
+	}
+	c.ticks++
+	msg.Time = c.ticks * int64(c.tick)
+	atomic.StoreInt64(&c.now, msg.Time)
+	c.notifyList.Iterate(func(ln *TickListener) bool {
+		ln.tick(msg)
+		return true
+	})
+	c.notifyList.Iterate(func(ln *TickListener) bool {
+		if r, ok := ln.owner.(*Reactor); ok {
+			r.awaitTick(ln.next.Tick - 1)
+		}
+		return true
+	})
+}
@@ -0,0 +1,92 @@
+package reactor
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/moontrade/kirana/pkg/counter"
+)
+
+type wakeAfterTask struct {
+	after   time.Duration
+	started counter.Counter
+	woke    counter.Counter
+	at      int64
+}
+
+func (w *wakeAfterTask) Poll(ctx Context) error {
+	if ctx.Reason == ReasonStart {
+		w.started.Incr()
+		ctx.WakeAfter(w.after)
+		return nil
+	}
+	w.at = ctx.Time
+	w.woke.Incr()
+	return nil
+}
+
+func newManualReactor(t *testing.T) (*Reactor, *ManualClock) {
+	clock := NewManualClock(time.Millisecond * 25)
+	r, err := NewReactor(Config{Level1Wheel: NewWheel(Millis25), Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Start()
+	// Wait for the Reactor to register with the clock.
+	for clock.notifyList.Len() == 0 {
+		runtime.Gosched()
+	}
+	return r, clock
+}
+
+func TestManualClockInterval(t *testing.T) {
+	r, clock := newManualReactor(t)
+	defer r.Close(context.Background())
+
+	c := new(counter.Counter)
+	if _, err := r.SpawnInterval(&SimpleTask{c: c}, time.Millisecond*100); err != nil {
+		t.Fatal(err)
+	}
+	for c.Load() == 0 {
+		runtime.Gosched()
+	}
+	clock.Advance(time.Second)
+	if c.Load() != 11 {
+		t.Fatal("expected 11 polls, got", c.Load())
+	}
+	clock.Advance(time.Millisecond * 99)
+	if c.Load() != 11 {
+		t.Fatal("expected 11 polls, got", c.Load())
+	}
+	clock.Advance(time.Millisecond)
+	if c.Load() != 12 {
+		t.Fatal("expected 12 polls, got", c.Load())
+	}
+}
+
+func TestManualClockWakeAfter(t *testing.T) {
+	r, clock := newManualReactor(t)
+	defer r.Close(context.Background())
+
+	task := &wakeAfterTask{after: time.Millisecond * 250}
+	if _, err := r.Spawn(task); err != nil {
+		t.Fatal(err)
+	}
+	start := clock.Now()
+	for task.started.Load() == 0 {
+		runtime.Gosched()
+	}
+	clock.Advance(time.Millisecond * 225)
+	if task.woke.Load() != 0 {
+		t.Fatal("woke early")
+	}
+	clock.Advance(time.Millisecond * 25)
+	if task.woke.Load() != 1 {
+		t.Fatal("expected wake")
+	}
+	if elapsed := time.Duration(task.at - start); elapsed != time.Millisecond*250 {
+		t.Fatal("expected wake at 250ms, got", elapsed)
+	}
+}
@@ -0,0 +1,335 @@
+package reactor
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a sequence of wall-clock times. See Reactor.SpawnSchedule.
+type Schedule interface {
+	// Next returns the first time of the Schedule strictly after t or the zero Time
+	// if there is none.
+	Next(t time.Time) time.Time
+}
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// maxCronDays bounds the search for the next time of a Cron which never matches
+// such as the 30th of February.
+const maxCronDays = 366 * 5
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+var (
+	cronMonths = []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	cronDays   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// Cron is a Schedule parsed from a cron expression and evaluated in the wall-clock
+// time of its Location.
+//
+// Daylight saving transitions are handled like cron(8). Times in the hour skipped
+// when clocks spring forward are shifted by the length of the gap and times in the
+// hour repeated when clocks fall back run once unless the hour field is a wildcard.
+type Cron struct {
+	second   uint64
+	minute   uint64
+	hour     uint64
+	dom      uint64
+	month    uint64
+	dow      uint64
+	domStar  bool
+	dowStar  bool
+	hourStar bool
+	loc      *time.Location
+}
+
+// ParseCron parses a cron expression of 5 fields "minute hour day-of-month month
+// day-of-week" or 6 fields with a leading seconds field. Fields accept "*", "?",
+// lists, ranges, steps and the names of months and days. The descriptors @yearly,
+// @monthly, @weekly, @daily and @hourly are also accepted. A "CRON_TZ=<zone>" or
+// "TZ=<zone>" prefix overrides loc which defaults to time.Local.
+func ParseCron(spec string, loc *time.Location) (*Cron, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		i := strings.IndexByte(spec, ' ')
+		if i < 0 {
+			return nil, fmt.Errorf("%w: missing fields after time zone in %q", ErrInvalidSchedule, spec)
+		}
+		zone, err := time.LoadLocation(spec[strings.IndexByte(spec, '=')+1 : i])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSchedule, err)
+		}
+		loc = zone
+		spec = strings.TrimSpace(spec[i:])
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	if strings.HasPrefix(spec, "@") {
+		expanded, ok := cronDescriptors[spec]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown descriptor %q", ErrInvalidSchedule, spec)
+		}
+		spec = expanded
+	}
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("%w: expected 5 or 6 fields in %q", ErrInvalidSchedule, spec)
+	}
+	c := &Cron{loc: loc}
+	var err error
+	if c.second, _, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if c.minute, _, err = parseCronField(fields[1], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if c.hour, c.hourStar, err = parseCronField(fields[2], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if c.dom, c.domStar, err = parseCronField(fields[3], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if c.month, _, err = parseCronField(fields[4], 1, 12, cronMonths); err != nil {
+		return nil, err
+	}
+	if c.dow, c.dowStar, err = parseCronField(fields[5], 0, 7, cronDays); err != nil {
+		return nil, err
+	}
+	// 7 is also Sunday.
+	if c.dow&(1<<7) != 0 {
+		c.dow = c.dow&^(1<<7) | 1
+	}
+	return c, nil
+}
+
+// MustParseCron is ParseCron that panics on error.
+func MustParseCron(spec string, loc *time.Location) *Cron {
+	c, err := ParseCron(spec, loc)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func parseCronField(field string, min, max int, names []string) (bits uint64, star bool, err error) {
+	for _, part := range strings.Split(field, ",") {
+		lo, hi, step := min, max, 1
+		rng := part
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			rng = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, false, fmt.Errorf("%w: bad step in %q", ErrInvalidSchedule, part)
+			}
+		}
+		switch {
+		case rng == "*" || rng == "?":
+			star = true
+		case strings.IndexByte(rng, '-') > 0:
+			i := strings.IndexByte(rng, '-')
+			if lo, err = parseCronValue(rng[:i], min, max, names); err != nil {
+				return 0, false, err
+			}
+			if hi, err = parseCronValue(rng[i+1:], min, max, names); err != nil {
+				return 0, false, err
+			}
+			if hi < lo {
+				return 0, false, fmt.Errorf("%w: bad range %q", ErrInvalidSchedule, part)
+			}
+		default:
+			if lo, err = parseCronValue(rng, min, max, names); err != nil {
+				return 0, false, err
+			}
+			if rng != part {
+				// "5/15" is "5-max/15".
+				hi = max
+			} else {
+				hi = lo
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, star, nil
+}
+
+func parseCronValue(s string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("%w: %q is not in [%d, %d]", ErrInvalidSchedule, s, min, max)
+	}
+	return v, nil
+}
+
+// Location returns the time zone the Cron is evaluated in.
+func (c *Cron) Location() *time.Location { return c.loc }
+
+func (c *Cron) Next(t time.Time) time.Time {
+	y, m, d := t.In(c.loc).Date()
+	// Dates are iterated in UTC which is free of daylight saving.
+	date := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < maxCronDays; i++ {
+		if c.month&(1<<uint(date.Month())) == 0 {
+			date = time.Date(date.Year(), date.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.matchDay(date) {
+			if next, ok := c.nextInDay(date, t); ok {
+				return next
+			}
+		}
+		date = date.AddDate(0, 0, 1)
+	}
+	return time.Time{}
+}
+
+// matchDay matches either day field when both are restricted like cron(8).
+func (c *Cron) matchDay(date time.Time) bool {
+	dom := c.dom&(1<<uint(date.Day())) != 0
+	dow := c.dow&(1<<uint(date.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// nextInDay returns the first time on date after t.
+func (c *Cron) nextInDay(date, t time.Time) (time.Time, bool) {
+	y, mo, d := date.Date()
+	for h := 0; h < 24; h++ {
+		if c.hour&(1<<uint(h)) == 0 || !c.wallAfter(y, mo, d, h, 59, 59, t) {
+			continue
+		}
+		for m := 0; m < 60; m++ {
+			if c.minute&(1<<uint(m)) == 0 || !c.wallAfter(y, mo, d, h, m, 59, t) {
+				continue
+			}
+			for s := 0; s < 60; s++ {
+				if c.second&(1<<uint(s)) == 0 {
+					continue
+				}
+				first, second, gap := wallTimes(y, mo, d, h, m, s, c.loc)
+				switch {
+				case gap && c.hourStar:
+					// The shifted time is also reached by the wildcard hour.
+				case first.After(t):
+					return first, true
+				case c.hourStar && !second.IsZero() && second.After(t):
+					return second, true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// wallAfter returns true if the last occurrence of the wall-clock time is after t.
+func (c *Cron) wallAfter(y int, mo time.Month, d, h, m, s int, t time.Time) bool {
+	first, second, _ := wallTimes(y, mo, d, h, m, s, c.loc)
+	if !second.IsZero() {
+		return second.After(t)
+	}
+	return first.After(t)
+}
+
+// wallTimes returns the occurrences of a wall-clock time. second is set for times
+// repeated when clocks fall back and gap is true for times skipped when clocks
+// spring forward in which case first is shifted past the gap.
+func wallTimes(y int, mo time.Month, d, h, m, s int, loc *time.Location) (first, second time.Time, gap bool) {
+	first = time.Date(y, mo, d, h, m, s, 0, loc)
+	if first.Hour() != h || first.Minute() != m {
+		// Apply the offset before the gap which lands after it.
+		_, offset := first.Add(-time.Hour * 3).Zone()
+		wall := time.Date(y, mo, d, h, m, s, 0, time.UTC)
+		return wall.Add(-time.Duration(offset) * time.Second).In(loc), time.Time{}, true
+	}
+	// Repeated wall-clock times are an hour apart.
+	if e := first.Add(-time.Hour); sameWall(e, first) {
+		return e, first, false
+	}
+	if l := first.Add(time.Hour); sameWall(l, first) {
+		return first, l, false
+	}
+	return first, time.Time{}, false
+}
+
+func sameWall(a, b time.Time) bool {
+	return a.Day() == b.Day() && a.Hour() == b.Hour() && a.Minute() == b.Minute() && a.Second() == b.Second()
+}
+
+// Aligned is a Schedule of the wall-clock times every Every from midnight plus
+// Offset. For example, every minute on the minute or 09:30 every day.
+//
+// Times skipped when clocks spring forward are shifted by the length of the gap and
+// times repeated when clocks fall back run once.
+type Aligned struct {
+	every  time.Duration
+	offset time.Duration
+	loc    *time.Location
+}
+
+// Align creates an Aligned Schedule in loc which defaults to time.Local. every must
+// divide 24 hours and offset must be less than every.
+func Align(every, offset time.Duration, loc *time.Location) (*Aligned, error) {
+	if every <= 0 || (time.Hour*24)%every != 0 {
+		return nil, fmt.Errorf("%w: %s does not divide 24h", ErrInvalidSchedule, every)
+	}
+	if offset < 0 || offset >= every {
+		return nil, fmt.Errorf("%w: offset %s is not in [0, %s)", ErrInvalidSchedule, offset, every)
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	return &Aligned{every: every, offset: offset, loc: loc}, nil
+}
+
+// Location returns the time zone the Aligned is evaluated in.
+func (a *Aligned) Location() *time.Location { return a.loc }
+
+func (a *Aligned) Next(t time.Time) time.Time {
+	local := t.In(a.loc)
+	y, m, d := local.Date()
+	elapsed := time.Duration(local.Hour())*time.Hour +
+		time.Duration(local.Minute())*time.Minute +
+		time.Duration(local.Second())*time.Second +
+		time.Duration(local.Nanosecond())
+	k := int64(0)
+	if elapsed > a.offset {
+		k = int64((elapsed - a.offset) / a.every)
+	}
+	for day := 0; day < 3; day++ {
+		for wall := a.offset + time.Duration(k)*a.every; wall < time.Hour*24; wall = a.offset + time.Duration(k)*a.every {
+			next, _, _ := wallTimes(y, m, d+day, int(wall/time.Hour), int(wall/time.Minute%60), int(wall/time.Second%60), a.loc)
+			next = next.Add(wall % time.Second)
+			if next.After(t) {
+				return next
+			}
+			// Skip the repeated hour when clocks fall back.
+			k += int64(t.Sub(next)/a.every) + 1
+		}
+		k = 0
+	}
+	return time.Time{}
+}
@@ -0,0 +1,137 @@
+package reactor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("tzdata unavailable", err)
+	}
+	for _, tc := range []struct {
+		spec  string
+		after string
+		want  []string
+	}{
+		{"* * * * *", "2023-06-01T09:29:30-04:00", []string{"2023-06-01T09:30:00-04:00", "2023-06-01T09:31:00-04:00"}},
+		{"30 9 * * MON-FRI", "2023-06-02T09:30:00-04:00", []string{"2023-06-05T09:30:00-04:00", "2023-06-06T09:30:00-04:00"}},
+		{"*/15 * * * * *", "2023-06-01T09:29:50-04:00", []string{"2023-06-01T09:30:00-04:00", "2023-06-01T09:30:15-04:00"}},
+		{"0 0 1,15 * *", "2023-01-15T00:00:00-05:00", []string{"2023-02-01T00:00:00-05:00", "2023-02-15T00:00:00-05:00"}},
+		{"@hourly", "2023-06-01T09:29:30-04:00", []string{"2023-06-01T10:00:00-04:00"}},
+		{"0 0 29 2 *", "2023-01-01T00:00:00-05:00", []string{"2024-02-29T00:00:00-05:00"}},
+		// Clocks spring forward at 02:00 on 2023-03-12.
+		{"30 2 * * *", "2023-03-11T03:00:00-05:00", []string{"2023-03-12T03:30:00-04:00", "2023-03-13T02:30:00-04:00"}},
+		{"30 * * * *", "2023-03-12T01:00:00-05:00", []string{"2023-03-12T01:30:00-05:00", "2023-03-12T03:30:00-04:00"}},
+		// Clocks fall back at 02:00 on 2023-11-05.
+		{"30 1 * * *", "2023-11-05T00:00:00-04:00", []string{"2023-11-05T01:30:00-04:00", "2023-11-06T01:30:00-05:00"}},
+		{"30 * * * *", "2023-11-05T01:00:00-04:00", []string{"2023-11-05T01:30:00-04:00", "2023-11-05T01:30:00-05:00", "2023-11-05T02:30:00-05:00"}},
+	} {
+		c, err := ParseCron(tc.spec, ny)
+		if err != nil {
+			t.Fatal(tc.spec, err)
+		}
+		next, _ := time.Parse(time.RFC3339, tc.after)
+		for _, want := range tc.want {
+			next = c.Next(next)
+			if got := next.Format(time.RFC3339); got != want {
+				t.Fatalf("%q after %s: expected %s got %s", tc.spec, tc.after, want, got)
+			}
+		}
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "@fortnightly", "CRON_TZ=Nowhere/Nope * * * * *"} {
+		if _, err := ParseCron(spec, time.UTC); err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+	c, err := ParseCron("CRON_TZ=UTC 0 0 * * *", time.Local)
+	if err != nil || c.Location() != time.UTC {
+		t.Fatal("expected CRON_TZ to override the location", err)
+	}
+}
+
+func TestAlignedNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("tzdata unavailable", err)
+	}
+	if _, err = Align(time.Minute*7, 0, ny); err == nil {
+		t.Fatal("expected 7m to be rejected")
+	}
+	for _, tc := range []struct {
+		every, offset time.Duration
+		after         string
+		want          []string
+	}{
+		{time.Minute, 0, "2023-06-01T09:29:30.5-04:00", []string{"2023-06-01T09:30:00-04:00", "2023-06-01T09:31:00-04:00"}},
+		{time.Hour * 24, time.Hour*9 + time.Minute*30, "2023-06-01T09:30:00-04:00", []string{"2023-06-02T09:30:00-04:00"}},
+		{time.Hour * 24, time.Hour*9 + time.Minute*30, "2023-03-11T10:00:00-05:00", []string{"2023-03-12T09:30:00-04:00"}},
+		{time.Hour * 24, time.Hour*2 + time.Minute*30, "2023-03-12T00:00:00-05:00", []string{"2023-03-12T03:30:00-04:00", "2023-03-13T02:30:00-04:00"}},
+		{time.Hour, 0, "2023-11-05T00:30:00-04:00", []string{"2023-11-05T01:00:00-04:00", "2023-11-05T02:00:00-05:00"}},
+		{time.Hour, time.Minute * 30, "2023-03-12T01:00:00-05:00", []string{"2023-03-12T01:30:00-05:00", "2023-03-12T03:30:00-04:00", "2023-03-12T04:30:00-04:00"}},
+	} {
+		a, err := Align(tc.every, tc.offset, ny)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, _ := time.Parse(time.RFC3339, tc.after)
+		for _, want := range tc.want {
+			next = a.Next(next)
+			if got := next.Format(time.RFC3339); got != want {
+				t.Fatalf("every %s after %s: expected %s got %s", tc.every, tc.after, want, got)
+			}
+		}
+	}
+}
+
+type scheduleRecorder struct {
+	scheduled []time.Time
+	reasons   []PollReason
+}
+
+func (s *scheduleRecorder) Poll(ctx Context) error {
+	if ctx.Reason != ReasonStart {
+		s.scheduled = append(s.scheduled, ctx.Scheduled)
+		s.reasons = append(s.reasons, ctx.Reason)
+	}
+	return nil
+}
+
+func TestReactorSpawnSchedule(t *testing.T) {
+	epoch := time.Date(2023, 6, 1, 9, 29, 59, int(time.Millisecond*500), time.UTC)
+	clock := NewManualClock(time.Millisecond * 25)
+	r, err := NewReactor(Config{
+		Level1Wheel: NewWheel(Millis25),
+		Clock:       clock,
+		Epoch:       epoch,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	everySecond, err := Align(time.Second, 0, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := &scheduleRecorder{}
+	if _, err = r.SpawnSchedule(task, everySecond); err != nil {
+		t.Fatal(err)
+	}
+	r.onWakeMessage(0)
+	// Drive the Reactor tick by tick for 1.5s.
+	for tick := int64(1); tick <= 60; tick++ {
+		clock.Advance(time.Millisecond * 25)
+		r.onWakeMessage(tick)
+	}
+	if len(task.scheduled) != 2 {
+		t.Fatalf("expected 2 scheduled polls, got %v", task.scheduled)
+	}
+	for i, want := range []time.Time{epoch.Add(time.Millisecond * 500), epoch.Add(time.Millisecond * 1500)} {
+		if !task.scheduled[i].Equal(want) || task.reasons[i] != ReasonInterval {
+			t.Fatalf("poll %d: expected %s got %s reason %d", i, want, task.scheduled[i], task.reasons[i])
+		}
+	}
+}
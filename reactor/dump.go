@@ -0,0 +1,311 @@
+package reactor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// DefaultDumpTimeout is how long a snapshot waits for the Reactor goroutine before
+// falling back to a best-effort snapshot of a stalled Reactor.
+const DefaultDumpTimeout = time.Second
+
+// TaskInfo is a snapshot of a Task.
+type TaskInfo struct {
+	ID       int64  `json:"id"`
+	Reactor  string `json:"reactor"`
+	Future   string `json:"future"`
+	State    string `json:"state"`
+	Priority string `json:"priority"`
+	// Polling is true if the Task is being polled when the snapshot was taken.
+	Polling       bool          `json:"polling,omitempty"`
+	Interval      time.Duration `json:"interval,omitempty"`
+	NextScheduled time.Time     `json:"nextScheduled,omitempty"`
+	Wakes         int64         `json:"wakes"`
+	Intervals     int64         `json:"intervals"`
+	Polls         int64         `json:"polls"`
+	PollsDur      time.Duration `json:"pollsDur,omitempty"`
+	Restarts      int           `json:"restarts,omitempty"`
+	// BudgetOverruns, Deferrals and MaxPollDur identify Tasks that exceed their poll
+	// budget. See Config.PollBudget.
+	BudgetOverruns int64         `json:"budgetOverruns,omitempty"`
+	Deferrals      int64         `json:"deferrals,omitempty"`
+	MaxPollDur     time.Duration `json:"maxPollDur,omitempty"`
+	Started        time.Time     `json:"started"`
+	LastPoll       time.Time     `json:"lastPoll,omitempty"`
+	// LastPollAgo is the time since the last poll. An interval Task with a value much
+	// greater than its Interval is not being polled.
+	LastPollAgo time.Duration `json:"lastPollAgo,omitempty"`
+	// Placement lists where the Task is scheduled such as "level1/250ms/wake",
+	// "level2/5s/interval" or "timer".
+	Placement []string `json:"placement,omitempty"`
+}
+
+// ReactorInfo is a snapshot of a Reactor and its Tasks.
+type ReactorInfo struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Stalled is true if the Reactor goroutine did not respond in time and the
+	// snapshot was taken from another goroutine on a best-effort basis.
+	Stalled bool    `json:"stalled,omitempty"`
+	Tick    int64   `json:"tick"`
+	Load    float64 `json:"load"`
+	// PollingTask is the ID of the Task being polled and PollingFor how long it has
+	// been polled for.
+	PollingTask int64         `json:"pollingTask,omitempty"`
+	PollingFor  time.Duration `json:"pollingFor,omitempty"`
+	Timers      int           `json:"timers"`
+	Tasks       []TaskInfo    `json:"tasks"`
+}
+
+// Tasks returns a snapshot of the Tasks of the Reactor ordered by ID.
+func (r *Reactor) Tasks() []TaskInfo {
+	return r.Info(DefaultDumpTimeout).Tasks
+}
+
+// Info returns a snapshot of the Reactor taken on its goroutine. If the Reactor does
+// not respond within timeout the snapshot is taken from the calling goroutine and
+// marked Stalled.
+func (r *Reactor) Info(timeout time.Duration) ReactorInfo {
+	if r.CheckGID() {
+		return r.info(false)
+	}
+	ch := make(chan ReactorInfo, 1)
+	go r.InvokePriority(PriorityHigh, func() {
+		ch <- r.info(false)
+	})
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case info := <-ch:
+		return info
+	case <-t.C:
+		return r.info(true)
+	}
+}
+
+func (r *Reactor) info(stalled bool) ReactorInfo {
+	now := r.nanotime()
+	info := ReactorInfo{
+		ID:      r.id,
+		Name:    r.config.Name,
+		Stalled: stalled,
+		Tick:    atomic.LoadInt64(&r.processed),
+		Load:    r.Load(),
+	}
+	polling := atomic.LoadInt64(&r.polling)
+	if polling != 0 {
+		info.PollingTask = polling
+		info.PollingFor = time.Duration(now - atomic.LoadInt64(&r.pollingSince))
+	}
+	var placement map[*Task][]string
+	if !stalled {
+		// Wheels and timers are only safe to walk on the Reactor goroutine.
+		info.Timers = len(r.timers)
+		placement = r.placement()
+	}
+	r.tasks.Scan(func(id int64, task *Task) bool {
+		info.Tasks = append(info.Tasks, r.taskInfo(now, task, polling, placement[task]))
+		return true
+	})
+	sort.Slice(info.Tasks, func(i, j int) bool {
+		return info.Tasks[i].ID < info.Tasks[j].ID
+	})
+	return info
+}
+
+func (r *Reactor) taskInfo(now int64, task *Task, polling int64, placement []string) TaskInfo {
+	ti := TaskInfo{
+		ID:             task.id,
+		Reactor:        r.config.Name,
+		Future:         futureName(task.future),
+		State:          "running",
+		Priority:       task.priority.String(),
+		Polling:        polling == task.id,
+		Interval:       task.interval,
+		Wakes:          task.wakes,
+		Intervals:      task.intervals,
+		Polls:          task.polls,
+		PollsDur:       time.Duration(task.pollsDur),
+		Restarts:       task.restarts,
+		BudgetOverruns: task.overruns,
+		Deferrals:      task.deferrals,
+		MaxPollDur:     time.Duration(task.maxPollDur),
+		Started:        r.WallTime(task.started),
+		Placement:      placement,
+	}
+	switch {
+	case task.stop:
+		ti.State = "stopping"
+	case task.restartAt > 0:
+		ti.State = "restarting"
+	case task.reactor != r:
+		ti.State = "migrating"
+	}
+	if task.cron != nil {
+		ti.NextScheduled = task.cron.next
+	}
+	if task.lastPoll > 0 {
+		ti.LastPoll = r.WallTime(task.lastPoll)
+		ti.LastPollAgo = time.Duration(now - task.lastPoll)
+	}
+	return ti
+}
+
+// placement maps every Task in the wheels and timer heap to where it is scheduled.
+func (r *Reactor) placement() map[*Task][]string {
+	m := make(map[*Task][]string)
+	for level, w := range [...]*Wheel{&r.tickWheel, &r.level2Wheel, &r.level3Wheel} {
+		for i, lists := range w.wheel {
+			for _, list := range lists {
+				for idx := 0; idx < list.size; idx++ {
+					slot := list.get(idx)
+					if slot.task == nil {
+						continue
+					}
+					kind := "interval"
+					if slot.fn != nil {
+						kind = "schedule"
+					} else if slot.wake {
+						kind = "wake"
+					}
+					m[slot.task] = append(m[slot.task],
+						fmt.Sprintf("level%d/%s/%s", level+1, w.durations[i], kind))
+				}
+			}
+		}
+	}
+	for _, t := range r.timers {
+		if t.task != nil {
+			m[t.task] = append(m[t.task], "timer")
+		}
+	}
+	return m
+}
+
+func futureName(future Future) string {
+	if future == nil {
+		return ""
+	}
+	return reflect.TypeOf(future).String()
+}
+
+// Dump returns a snapshot of every Reactor. Stalled Reactors are waited on for at
+// most timeout each.
+func Dump(timeout time.Duration) []ReactorInfo {
+	loops := Reactors()
+	infos := make([]ReactorInfo, len(loops))
+	done := make(chan struct{}, len(loops))
+	for i, r := range loops {
+		go func(i int, r *Reactor) {
+			infos[i] = r.Info(timeout)
+			done <- struct{}{}
+		}(i, r)
+	}
+	for range loops {
+		<-done
+	}
+	return infos
+}
+
+// WriteDumpJSON writes the Dump of every Reactor as JSON.
+func WriteDumpJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Dump(DefaultDumpTimeout))
+}
+
+// WriteDumpText writes the Dump of every Reactor as human-readable tables.
+func WriteDumpText(w io.Writer) error {
+	for _, info := range Dump(DefaultDumpTimeout) {
+		if err := writeReactorText(w, &info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeReactorText(w io.Writer, info *ReactorInfo) error {
+	header := fmt.Sprintf("reactor %s id=%d tick=%d load=%.3f tasks=%d timers=%d",
+		info.Name, info.ID, info.Tick, info.Load, len(info.Tasks), info.Timers)
+	if info.Stalled {
+		header += " STALLED"
+	}
+	if info.PollingTask != 0 {
+		header += fmt.Sprintf(" polling=%d for %s", info.PollingTask, info.PollingFor)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, header)
+	fmt.Fprintln(tw, "ID\tFUTURE\tSTATE\tPRIORITY\tINTERVAL\tPOLLS\tWAKES\tINTERVALS\tMAX POLL\tOVERRUNS\tLAST POLL\tPLACEMENT")
+	for _, t := range info.Tasks {
+		state := t.State
+		if t.Polling {
+			state += "*"
+		}
+		interval := "-"
+		if t.Interval > 0 {
+			interval = t.Interval.String()
+		} else if !t.NextScheduled.IsZero() {
+			interval = t.NextScheduled.Format(time.RFC3339)
+		}
+		lastPoll := "-"
+		if !t.LastPoll.IsZero() {
+			lastPoll = t.LastPollAgo.String() + " ago"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\t%d\t%s\t%s\n",
+			t.ID, t.Future, state, t.Priority, interval, t.Polls, t.Wakes, t.Intervals,
+			t.MaxPollDur, t.BudgetOverruns, lastPoll, strings.Join(t.Placement, ","))
+	}
+	fmt.Fprintln(tw)
+	return tw.Flush()
+}
+
+// DumpHandler serves the Dump of every Reactor as text or as JSON when the format
+// query parameter is "json". It exposes Task and Future type names so it is not
+// mounted by default, e.g. mux.Handle("/debug/reactors", reactor.DumpHandler()).
+func DumpHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			_ = WriteDumpJSON(w)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_ = WriteDumpText(w)
+	})
+}
+
+// DumpOnSignal writes the text Dump to w, os.Stderr if nil, each time one of sigs is
+// received. Registering syscall.SIGQUIT replaces the runtime's goroutine dump.
+// Returns a func that stops it.
+func DumpOnSignal(w io.Writer, sigs ...os.Signal) (stop func()) {
+	if w == nil {
+		w = os.Stderr
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				_ = WriteDumpText(w)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
@@ -0,0 +1,62 @@
+package reactor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+type idleTask struct{}
+
+func (idleTask) Poll(ctx Context) error { return nil }
+
+func TestReactorTasks(t *testing.T) {
+	r, err := NewReactor(Config{Name: "dump", Level1Wheel: NewWheel(Millis25)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Start()
+	defer r.Close(context.Background())
+
+	interval, err := r.SpawnInterval(idleTask{}, time.Millisecond*50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sleeping := &sleeper{after: time.Second * 5}
+	if _, err = r.Spawn(sleeping); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 200)
+
+	info := r.Info(time.Second)
+	if info.Stalled || info.Name != "dump" || len(info.Tasks) != 2 {
+		t.Fatalf("unexpected info %+v", info)
+	}
+	ti := info.Tasks[0]
+	if ti.ID != interval.ID() || ti.Interval != time.Millisecond*50 || ti.Polls < 2 || ti.State != "running" {
+		t.Fatalf("unexpected interval task %+v", ti)
+	}
+	if len(ti.Placement) != 1 || !strings.HasPrefix(ti.Placement[0], "level1/50ms/interval") {
+		t.Fatalf("unexpected interval placement %v", ti.Placement)
+	}
+	if p := info.Tasks[1].Placement; len(p) != 1 || !strings.HasPrefix(p[0], "level2/") || !strings.HasSuffix(p[0], "/wake") {
+		t.Fatalf("unexpected wake placement %v", p)
+	}
+	if !strings.HasSuffix(info.Tasks[1].Future, "reactor.sleeper") {
+		t.Fatalf("unexpected future name %s", info.Tasks[1].Future)
+	}
+
+	var buf bytes.Buffer
+	if err = writeReactorText(&buf, &info); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "reactor dump") || !strings.Contains(buf.String(), "level1/50ms/interval") {
+		t.Fatalf("unexpected text dump\n%s", buf.String())
+	}
+	if _, err = json.Marshal(info); err != nil {
+		t.Fatal(err)
+	}
+}
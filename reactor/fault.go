@@ -0,0 +1,141 @@
+package reactor
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/moontrade/kirana/pkg/util"
+)
+
+// FaultAction is how the Reactor handles a Task that panicked or returned an error.
+type FaultAction uint8
+
+const (
+	FaultDefault FaultAction = 0 // FaultDefault stops on panic and ignores errors
+	FaultIgnore  FaultAction = 1 // FaultIgnore keeps the Task running
+	FaultStop    FaultAction = 2 // FaultStop stops the Task delivering the error as the CloseEvent Reason
+	FaultRestart FaultAction = 3 // FaultRestart polls the Task with ReasonStart again after a backoff
+)
+
+const (
+	DefaultRestartBackoffMin = time.Second
+	DefaultRestartBackoffMax = time.Minute
+)
+
+// PanicHandler is invoked on the Reactor goroutine when a Task panics. task is nil
+// for funcs passed to Invoke. For ReasonClose the Task is already stopped and the
+// action is ignored.
+type PanicHandler func(task *Task, reason PollReason, err error, stack []byte) FaultAction
+
+// ErrorHandler is invoked on the Reactor goroutine when Poll returns an error other
+// than ErrStop.
+type ErrorHandler func(task *Task, reason PollReason, err error) FaultAction
+
+func (r *Reactor) onPanic(now int64, task *Task, reason PollReason, e any) FaultAction {
+	err := util.PanicToError(e)
+	stack := debug.Stack()
+	r.panics.Incr()
+	action := FaultStop
+	if handler := r.config.PanicHandler; handler != nil {
+		if a := r.handlePanic(handler, task, reason, err, stack); a != FaultDefault {
+			action = a
+		}
+	}
+	return r.fault(now, task, action, err)
+}
+
+func (r *Reactor) onError(now int64, task *Task, reason PollReason, err error) FaultAction {
+	r.pollErrors.Incr()
+	action := FaultIgnore
+	if handler := r.config.ErrorHandler; handler != nil {
+		if a := r.handleError(handler, task, reason, err); a != FaultDefault {
+			action = a
+		}
+	}
+	return r.fault(now, task, action, err)
+}
+
+func (r *Reactor) handlePanic(
+	handler PanicHandler,
+	task *Task,
+	reason PollReason,
+	err error,
+	stack []byte,
+) (action FaultAction) {
+	defer func() {
+		if e := recover(); e != nil {
+			//logger.Error(util.PanicToError(e), "PanicHandler panic")
+			action = FaultDefault
+		}
+	}()
+	return handler(task, reason, err, stack)
+}
+
+func (r *Reactor) handleError(
+	handler ErrorHandler,
+	task *Task,
+	reason PollReason,
+	err error,
+) (action FaultAction) {
+	defer func() {
+		if e := recover(); e != nil {
+			//logger.Error(util.PanicToError(e), "ErrorHandler panic")
+			action = FaultDefault
+		}
+	}()
+	return handler(task, reason, err)
+}
+
+// fault applies action to task and returns the action taken.
+func (r *Reactor) fault(now int64, task *Task, action FaultAction, err error) FaultAction {
+	if task == nil || task.stop || task.reactor != r {
+		return FaultIgnore
+	}
+	switch action {
+	case FaultStop:
+		r.stopTask(now, task, err)
+		return FaultStop
+	case FaultRestart:
+		return r.restart(now, task, err)
+	default:
+		return FaultIgnore
+	}
+}
+
+// restart schedules task to be polled with ReasonStart after an exponential backoff.
+// The Task is stopped once it exceeds Config.MaxRestarts.
+func (r *Reactor) restart(now int64, task *Task, err error) FaultAction {
+	task.restarts++
+	if max := r.config.MaxRestarts; max > 0 && task.restarts > max {
+		r.stopTask(now, task, err)
+		return FaultStop
+	}
+	backoff := r.config.RestartBackoffMin
+	for i := 1; i < task.restarts && backoff < r.config.RestartBackoffMax; i++ {
+		backoff <<= 1
+	}
+	if backoff > r.config.RestartBackoffMax {
+		backoff = r.config.RestartBackoffMax
+	}
+	r.restarts.Incr()
+	task.wakeAfter = 0
+	task.restartAt = now + int64(backoff)
+	r.schedule(task, backoff, true)
+	return FaultRestart
+}
+
+// maybeRestart polls a restarting Task with ReasonStart once its backoff has elapsed.
+// Returns true if the Task is restarting.
+func (r *Reactor) maybeRestart(now int64, task *Task) bool {
+	if task.restartAt == 0 {
+		return false
+	}
+	// Wheel slots are tick aligned and coarser wheels may wake early.
+	if remaining := task.restartAt - now; remaining > int64(r.tickDur) {
+		r.schedule(task, time.Duration(remaining), true)
+		return true
+	}
+	task.restartAt = 0
+	r.pollStart(now, task)
+	return true
+}
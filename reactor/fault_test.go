@@ -0,0 +1,135 @@
+package reactor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/moontrade/kirana/pkg/counter"
+)
+
+type panicTask struct {
+	panics int
+	starts counter.Counter
+	polls  counter.Counter
+	reason chan any
+}
+
+func (p *panicTask) Poll(ctx Context) error {
+	if ctx.Reason == ReasonStart {
+		p.starts.Incr()
+	}
+	if p.polls.Incr() <= int64(p.panics) {
+		panic("boom")
+	}
+	return nil
+}
+
+func (p *panicTask) PollClose(event CloseEvent) error {
+	p.reason <- event.Reason
+	return nil
+}
+
+func TestReactorPanicStops(t *testing.T) {
+	r, err := NewReactor(Config{Level1Wheel: NewWheel(Millis25)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Start()
+	defer r.Close(context.Background())
+
+	task := &panicTask{panics: 1, reason: make(chan any, 1)}
+	if _, err = r.Spawn(task); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case reason := <-task.reason:
+		if _, ok := reason.(error); !ok {
+			t.Fatal("expected panic error reason, got", reason)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("panicking task was not stopped")
+	}
+	if r.panics.Load() != 1 {
+		t.Fatal("expected 1 panic, got", r.panics.Load())
+	}
+}
+
+func TestReactorPanicRestart(t *testing.T) {
+	var stacks counter.Counter
+	r, err := NewReactor(Config{
+		Level1Wheel:       NewWheel(Millis25),
+		RestartBackoffMin: time.Millisecond * 25,
+		RestartBackoffMax: time.Millisecond * 100,
+		PanicHandler: func(task *Task, reason PollReason, err error, stack []byte) FaultAction {
+			if len(stack) > 0 {
+				stacks.Incr()
+			}
+			return FaultRestart
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Start()
+	defer r.Close(context.Background())
+
+	task := &panicTask{panics: 2, reason: make(chan any, 1)}
+	if _, err = r.SpawnInterval(task, time.Millisecond*25); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second * 5)
+	for task.polls.Load() < 5 {
+		if time.Now().After(deadline) {
+			t.Fatal("task was not restarted")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if task.starts.Load() != 3 {
+		t.Fatal("expected 3 starts, got", task.starts.Load())
+	}
+	if stacks.Load() != 2 {
+		t.Fatal("expected 2 stacks, got", stacks.Load())
+	}
+}
+
+func TestReactorErrorHandler(t *testing.T) {
+	failed := errors.New("failed")
+	r, err := NewReactor(Config{
+		Level1Wheel: NewWheel(Millis25),
+		ErrorHandler: func(task *Task, reason PollReason, err error) FaultAction {
+			return FaultStop
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Start()
+	defer r.Close(context.Background())
+
+	task := &errorTask{err: failed, reason: make(chan any, 1)}
+	if _, err = r.Spawn(task); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case reason := <-task.reason:
+		if reason != failed {
+			t.Fatal("expected failed reason, got", reason)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("task was not stopped")
+	}
+}
+
+type errorTask struct {
+	err    error
+	reason chan any
+}
+
+func (e *errorTask) Poll(ctx Context) error { return e.err }
+
+func (e *errorTask) PollClose(event CloseEvent) error {
+	e.reason <- event.Reason
+	return nil
+}
@@ -1,6 +1,7 @@
 package reactor
 
 import (
+	"context"
 	"time"
 )
 
@@ -21,6 +22,7 @@ const (
 	ReasonIntervalBehind PollReason = 3 // ReasonIntervalBehind the Task has missed interval wakes due to overloaded Reactor
 	ReasonPing           PollReason = 4 // ReasonPing
 	ReasonClose          PollReason = 5 // ReasonClose the Task will immediately close on return
+	ReasonInvoke         PollReason = 6 // ReasonInvoke a func passed to Invoke. Only used for faults
 )
 
 type CloseEvent struct {
@@ -52,6 +54,9 @@ type Context struct {
 	After time.Duration
 	// Reason why Poll was called
 	Reason PollReason
+	// Scheduled is the intended wall-clock time of a ReasonInterval or
+	// ReasonIntervalBehind poll of a Task spawned with SpawnSchedule.
+	Scheduled time.Time
 }
 
 // SetInterval sets the interval for the task
@@ -69,11 +74,25 @@ func (p *Context) WakeAfter(duration time.Duration) {
 	p.Task.wakeAfter = duration
 }
 
+// SetPriority sets the priority of subsequent wakes of the task.
+func (p *Context) SetPriority(priority Priority) {
+	if priority.valid() {
+		p.Task.priority = priority
+	}
+}
+
 // Stop marks the task to be stopped and deleted
 func (p *Context) Stop() {
 	p.Task.stop = true
 }
 
+// Std returns a standard context.Context for downstream calls which is cancelled
+// when the Task stops. For Tasks spawned with SpawnContext it is derived from the
+// spawn context.
+func (p *Context) Std() context.Context {
+	return p.Task.Context()
+}
+
 // Reactor the Task belongs to.
 func (p *Context) Reactor() *Reactor {
 	return p.Task.reactor
@@ -34,3 +34,20 @@ func (t *OneShot) PollClose(event CloseEvent) error {
 	fmt.Println("one shot closed")
 	return nil
 }
+
+type CloseRecorder struct {
+	c      *counter.Counter
+	reason any
+	closed chan struct{}
+}
+
+func (t *CloseRecorder) Poll(ctx Context) error {
+	t.c.Incr()
+	return nil
+}
+
+func (t *CloseRecorder) PollClose(event CloseEvent) error {
+	t.reason = event.Reason
+	close(t.closed)
+	return nil
+}
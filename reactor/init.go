@@ -1,22 +1,22 @@
 package reactor
 
 import (
-	"runtime"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/moontrade/kirana/pkg/counter"
 	"github.com/moontrade/kirana/pkg/cow"
-	"github.com/moontrade/kirana/pkg/pmath"
 	"github.com/moontrade/kirana/pkg/runtimex"
 )
 
 var (
+	// idCounter assigns Task IDs which are unique across Reactors.
 	idCounter    counter.Counter
 	ticker       *Ticker
 	blocking     *BlockingPool
 	reactors     cow.Slice[*Reactor]
+	reactorIDs   counter.Counter
 	reactorsMask = uint32(0)
 	mu           sync.Mutex
 )
@@ -37,9 +37,30 @@ func initTicker(tickDur time.Duration) *Ticker {
 
 func NumReactors() int { return reactors.Len() }
 
+// Reactors returns a snapshot of all running Reactors.
+func Reactors() []*Reactor { return reactors.Snapshot() }
+
+// DefaultTicker returns the global Ticker or nil if not started.
+func DefaultTicker() *Ticker {
+	mu.Lock()
+	defer mu.Unlock()
+	return ticker
+}
+
+// DefaultBlockingPool returns the global BlockingPool or nil if Init was not called.
+func DefaultBlockingPool() *BlockingPool { return blocking }
+
 func NextReactor() *Reactor {
 	loops := reactors.Snapshot()
-	return loops[runtimex.Fastrand()&reactorsMask]
+	if len(loops) == 0 {
+		return nil
+	}
+	idx := runtimex.Fastrand() & reactorsMask
+	if int(idx) >= len(loops) {
+		// A Reactor was closed and removed.
+		idx %= uint32(len(loops))
+	}
+	return loops[idx]
 }
 
 func Init(
@@ -47,14 +68,23 @@ func Init(
 	tick Cadence,
 	queueSize int,
 	blockingQueueSize int,
+) {
+	InitAffinity(numLoops, tick, queueSize, blockingQueueSize, Affinity{})
+}
+
+// InitAffinity is Init with the Reactors, Ticker and BlockingPool threads pinned
+// according to affinity.
+func InitAffinity(
+	numLoops int,
+	tick Cadence,
+	queueSize int,
+	blockingQueueSize int,
+	affinity Affinity,
 ) {
 	if reactors.Len() > 0 {
 		return
 	}
-	if numLoops == 0 {
-		numLoops = runtime.GOMAXPROCS(0)
-	}
-	numLoops = pmath.CeilToPowerOf2(numLoops)
+	numLoops = numReactors(numLoops)
 	reactorsMask = uint32(numLoops - 1)
 	if queueSize < 1024 {
 		queueSize = 1024
@@ -62,11 +92,15 @@ func Init(
 	if blockingQueueSize < 64 {
 		blockingQueueSize = 64
 	}
-	blocking = NewBlockingPool(0, blockingQueueSize)
-	ticker = StartTicker(tick.Tick())
+	blocking = NewBlockingPoolOn(0, blockingQueueSize, affinity.Blocking)
+	ticker = StartTickerOn(tick.Tick(), affinity.Ticker)
 
 	l := make([]*Reactor, numLoops)
 	for i := 0; i < numLoops; i++ {
+		var cpu []int
+		if len(affinity.Reactors) > 0 {
+			cpu = []int{affinity.Reactors[i%len(affinity.Reactors)]}
+		}
 		loop, err := NewReactor(Config{
 			Name:         "ev-" + strconv.Itoa(i),
 			Level1Wheel:  NewWheel(tick),
@@ -76,6 +110,7 @@ func Init(
 			WakeQSize:    queueSize,
 			SpawnQSize:   queueSize,
 			LockOSThread: true,
+			CPU:          cpu,
 		})
 		if err != nil {
 			panic(err)
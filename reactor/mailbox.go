@@ -0,0 +1,205 @@
+package reactor
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+
+	"github.com/moontrade/kirana/pkg/counter"
+	"github.com/moontrade/kirana/pkg/mpmc"
+	"github.com/moontrade/kirana/pkg/spinlock"
+)
+
+// MailboxStats is a snapshot of the stats of a Mailbox.
+type MailboxStats struct {
+	Sent     int64
+	Received int64
+	// Full is the number of sends rejected with ErrQueueFull.
+	Full int64
+	// Wakes is the number of times the owner was woken which is less than Sent when
+	// wakes are coalesced.
+	Wakes int64
+	// Backpressure is the number of producer Tasks woken after waiting for space.
+	Backpressure int64
+}
+
+// Mailbox is a bounded queue of messages owned by a Task on one Reactor which any
+// goroutine or Task on another Reactor can send to. Sends wake the owner which are
+// coalesced until it receives again. Tasks sending with SendFrom to a full Mailbox
+// are woken once it drains below half its capacity.
+//
+// The Mailbox is closed when its owner stops. Recv must only be called by the owner.
+type Mailbox[T any] struct {
+	q        *mpmc.Bounded[T]
+	owner    *Task
+	ownerID  int64
+	waking   int64
+	closed   int64
+	lowWater int
+	waiters  []promiseWaiter
+	waiting  int64
+	mu       spinlock.Mutex
+
+	sent         counter.Counter
+	received     counter.Counter
+	full         counter.Counter
+	wakes        counter.Counter
+	backpressure counter.Counter
+}
+
+// NewMailbox creates a Mailbox owned by owner with at least capacity slots. Must be
+// called on the owner's Reactor goroutine, such as in Poll with ReasonStart.
+func NewMailbox[T any](owner *Task, capacity int) (*Mailbox[T], error) {
+	if owner == nil || owner.reactor == nil {
+		return nil, errors.New("task is not scheduled")
+	}
+	if owner.stop {
+		return nil, os.ErrClosed
+	}
+	m := &Mailbox[T]{
+		q:       mpmc.NewBounded[T](int64(capacity)),
+		owner:   owner,
+		ownerID: owner.id,
+	}
+	m.lowWater = m.q.Cap() / 2
+	owner.closers = append(owner.closers, func() {
+		_ = m.Close()
+	})
+	return m, nil
+}
+
+// Owner returns the Task that receives from the Mailbox.
+func (m *Mailbox[T]) Owner() *Task { return m.owner }
+
+func (m *Mailbox[T]) Len() int { return m.q.Len() }
+
+func (m *Mailbox[T]) Cap() int { return m.q.Cap() }
+
+func (m *Mailbox[T]) IsClosed() bool { return atomic.LoadInt64(&m.closed) != 0 }
+
+// Send enqueues msg and wakes the owner. Returns ErrQueueFull if the Mailbox is full
+// and os.ErrClosed once the owner has stopped.
+func (m *Mailbox[T]) Send(msg T) error {
+	if m.IsClosed() {
+		return os.ErrClosed
+	}
+	if !m.q.Enqueue(&msg) {
+		m.full.Incr()
+		return ErrQueueFull
+	}
+	m.sent.Incr()
+	m.wake()
+	return nil
+}
+
+// SendFrom is Send from the sender Task which is woken once the Mailbox has space
+// when it returns ErrQueueFull.
+func (m *Mailbox[T]) SendFrom(sender *Task, msg T) error {
+	err := m.Send(msg)
+	if err != ErrQueueFull || sender == nil {
+		return err
+	}
+	m.mu.Lock()
+	m.waiters = append(m.waiters, promiseWaiter{task: sender, id: sender.id})
+	atomic.StoreInt64(&m.waiting, int64(len(m.waiters)))
+	m.mu.Unlock()
+	// The owner may have drained the Mailbox before the sender was added.
+	if m.q.Len() <= m.lowWater || m.IsClosed() {
+		m.release()
+	}
+	return ErrQueueFull
+}
+
+// wake wakes the owner unless a wake is already pending.
+func (m *Mailbox[T]) wake() {
+	if !atomic.CompareAndSwapInt64(&m.waking, 0, 1) {
+		return
+	}
+	// The owner may have been stopped and recycled before the Mailbox was closed.
+	if m.owner.id != m.ownerID {
+		atomic.StoreInt64(&m.waking, 0)
+		return
+	}
+	if err := m.owner.Wake(); err != nil {
+		atomic.StoreInt64(&m.waking, 0)
+		return
+	}
+	m.wakes.Incr()
+}
+
+// Recv dequeues the next message. Returns false if the Mailbox is empty.
+func (m *Mailbox[T]) Recv() (msg T, ok bool) {
+	// Sends after this point wake the owner again.
+	atomic.StoreInt64(&m.waking, 0)
+	v := m.q.Dequeue()
+	if v == nil {
+		return msg, false
+	}
+	m.received.Incr()
+	m.maybeRelease()
+	return *v, true
+}
+
+// Drain dequeues up to max messages, all if max <= 0, into fn and returns the count.
+func (m *Mailbox[T]) Drain(max int, fn func(msg T)) int {
+	atomic.StoreInt64(&m.waking, 0)
+	if max <= 0 {
+		max = m.q.Cap()
+	}
+	count := m.q.DequeueMany(max, func(msg *T) {
+		fn(*msg)
+	})
+	m.received.Add(int64(count))
+	m.maybeRelease()
+	return count
+}
+
+func (m *Mailbox[T]) maybeRelease() {
+	if atomic.LoadInt64(&m.waiting) > 0 && m.q.Len() <= m.lowWater {
+		m.release()
+	}
+}
+
+// release wakes the producers waiting for space.
+func (m *Mailbox[T]) release() {
+	m.mu.Lock()
+	waiters := m.waiters
+	m.waiters = nil
+	atomic.StoreInt64(&m.waiting, 0)
+	m.mu.Unlock()
+	for _, waiter := range waiters {
+		// The producer may have been stopped and recycled while waiting.
+		if waiter.task.id != waiter.id {
+			continue
+		}
+		if waiter.task.Wake() == nil {
+			m.backpressure.Incr()
+		}
+	}
+}
+
+// Close closes the Mailbox to further sends and wakes the producers waiting for space.
+// Messages already sent can still be received.
+func (m *Mailbox[T]) Close() error {
+	if !atomic.CompareAndSwapInt64(&m.closed, 0, 1) {
+		return os.ErrClosed
+	}
+	m.release()
+	return nil
+}
+
+// PollClose closes the Mailbox. A Future owning a Mailbox may forward its PollClose.
+func (m *Mailbox[T]) PollClose(ev CloseEvent) error {
+	_ = m.Close()
+	return nil
+}
+
+func (m *Mailbox[T]) Stats() MailboxStats {
+	return MailboxStats{
+		Sent:         m.sent.Load(),
+		Received:     m.received.Load(),
+		Full:         m.full.Load(),
+		Wakes:        m.wakes.Load(),
+		Backpressure: m.backpressure.Load(),
+	}
+}
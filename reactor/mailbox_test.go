@@ -0,0 +1,168 @@
+package reactor
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+type mailboxConsumer struct {
+	mailbox  *Mailbox[int]
+	drain    bool
+	stop     bool
+	received []int
+}
+
+func (c *mailboxConsumer) Poll(ctx Context) (err error) {
+	if ctx.Reason == ReasonStart {
+		c.mailbox, err = NewMailbox[int](ctx.Task, 4)
+		return err
+	}
+	if c.stop {
+		ctx.Stop()
+		return nil
+	}
+	if c.drain {
+		c.mailbox.Drain(0, func(msg int) {
+			c.received = append(c.received, msg)
+		})
+	}
+	return nil
+}
+
+type mailboxProducer struct {
+	mailbox *Mailbox[int]
+	next    int
+	limit   int
+	blocked int
+	stop    bool
+}
+
+func (p *mailboxProducer) Poll(ctx Context) error {
+	if p.stop {
+		ctx.Stop()
+		return nil
+	}
+	for p.next < p.limit {
+		if err := p.mailbox.SendFrom(ctx.Task, p.next); err != nil {
+			p.blocked++
+			return nil
+		}
+		p.next++
+	}
+	return nil
+}
+
+func TestMailbox(t *testing.T) {
+	r, err := NewReactor(Config{
+		Level1Wheel: NewWheel(Millis25),
+		Clock:       NewManualClock(time.Millisecond * 25),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumer := &mailboxConsumer{drain: true}
+	owner, err := r.Spawn(consumer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.onWakeMessage(0)
+	m := consumer.mailbox
+	for i := 0; i < 3; i++ {
+		if err = m.Send(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := m.Stats(); s.Sent != 3 || s.Wakes != 1 {
+		t.Fatalf("expected 3 sends coalesced into 1 wake, got %+v", s)
+	}
+	r.onWakeMessage(0)
+	if len(consumer.received) != 3 || consumer.received[2] != 2 || owner.Wakes() != 1 {
+		t.Fatalf("expected 3 messages in 1 wake, got %v in %d", consumer.received, owner.Wakes())
+	}
+
+	// The producer fills the Mailbox and waits for space.
+	consumer.drain = false
+	producer := &mailboxProducer{mailbox: m, limit: 10}
+	if _, err = r.Spawn(producer); err != nil {
+		t.Fatal(err)
+	}
+	r.onWakeMessage(0)
+	if producer.next != 4 || producer.blocked != 1 || m.Stats().Full != 1 {
+		t.Fatalf("expected producer to block after 4 sends, next=%d blocked=%d", producer.next, producer.blocked)
+	}
+	consumer.drain = true
+	if err = owner.Wake(); err != nil {
+		t.Fatal(err)
+	}
+	r.onWakeMessage(0)
+	if producer.next != 10 || len(consumer.received) != 13 || m.Stats().Backpressure < 1 {
+		t.Fatalf("expected producer to be woken until done, next=%d received=%d stats=%+v",
+			producer.next, len(consumer.received), m.Stats())
+	}
+
+	// Stopping the owner closes the Mailbox.
+	consumer.stop = true
+	if err = owner.Wake(); err != nil {
+		t.Fatal(err)
+	}
+	r.onWakeMessage(0)
+	if err = m.Send(0); err != os.ErrClosed {
+		t.Fatalf("expected os.ErrClosed, got %v", err)
+	}
+}
+
+func TestMailboxProducerStopped(t *testing.T) {
+	r, err := NewReactor(Config{
+		Level1Wheel: NewWheel(Millis25),
+		Clock:       NewManualClock(time.Millisecond * 25),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumer := &mailboxConsumer{}
+	owner, err := r.Spawn(consumer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.onWakeMessage(0)
+	m := consumer.mailbox
+
+	producer := &mailboxProducer{mailbox: m, limit: 10}
+	task, err := r.Spawn(producer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.onWakeMessage(0)
+	if producer.blocked != 1 {
+		t.Fatalf("expected producer to block, blocked=%d", producer.blocked)
+	}
+
+	// The producer stops while waiting for space and its Task may be recycled.
+	producer.stop = true
+	if err = task.Wake(); err != nil {
+		t.Fatal(err)
+	}
+	r.onWakeMessage(0)
+	next, err := r.Spawn(&mailboxProducer{mailbox: m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.onWakeMessage(0)
+	wakes := next.Wakes()
+
+	consumer.drain = true
+	if err = owner.Wake(); err != nil {
+		t.Fatal(err)
+	}
+	r.onWakeMessage(0)
+	if len(consumer.received) != 4 {
+		t.Fatalf("expected 4 messages, got %v", consumer.received)
+	}
+	if s := m.Stats(); s.Backpressure != 0 {
+		t.Fatalf("expected stopped producer not to be woken, got %+v", s)
+	}
+	if next.Wakes() != wakes {
+		t.Fatalf("expected recycled Task not to be woken, got %d wakes", next.Wakes()-wakes)
+	}
+}
@@ -90,7 +90,7 @@ func (w *WakeLists) Ensure(reactorID int) bool {
 	if reactorID >= len(w.slots) {
 		numReactors := pmath.CeilToPowerOf2(NumReactors())
 		if numReactors <= reactorID {
-			numReactors = pmath.CeilToPowerOf2(reactorID)
+			numReactors = pmath.CeilToPowerOf2(reactorID + 1)
 		}
 		next := make([]*WakeList, numReactors)
 		if len(w.slots) > 0 {
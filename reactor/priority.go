@@ -0,0 +1,121 @@
+package reactor
+
+import (
+	"errors"
+	"math"
+
+	"github.com/moontrade/kirana/pkg/counter"
+	"github.com/moontrade/kirana/pkg/mpmc"
+)
+
+var ErrInvalidPriority = errors.New("invalid priority")
+
+// Priority is the queue class of Invokes, Wakes and Spawns. Each class has its own
+// queues so a burst of low priority work can't fill the queues of high priority work.
+type Priority uint8
+
+const (
+	PriorityNormal Priority = 0 // PriorityNormal is the default
+	PriorityHigh   Priority = 1 // PriorityHigh is always flushed fully before and between lower priority batches
+	PriorityLow    Priority = 2 // PriorityLow is flushed after PriorityNormal
+	NumPriorities           = 3
+)
+
+const (
+	DefaultPriorityWeightNormal = 256
+	DefaultPriorityWeightLow    = 32
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	case PriorityLow:
+		return "low"
+	default:
+		return "invalid"
+	}
+}
+
+func (p Priority) valid() bool { return p < NumPriorities }
+
+// PriorityStats is a snapshot of the stats of a Priority.
+type PriorityStats struct {
+	Invokes int64
+	Wakes   int64
+	Spawns  int64
+	// Deferred is the number of flushes that left work in the queues after
+	// reaching the Priority's weight.
+	Deferred int64
+}
+
+type priorityQueue struct {
+	wakeQ    *mpmc.BoundedWake[Task]
+	spawnQ   *mpmc.BoundedWake[Task]
+	invokeQ  *mpmc.BoundedWake[func()]
+	weight   int
+	invokes  counter.Counter
+	wakes    counter.Counter
+	spawns   counter.Counter
+	deferred counter.Counter
+}
+
+func newPriorityQueue(config *Config, weight int, wakeCh chan int64) priorityQueue {
+	if weight <= 0 {
+		weight = math.MaxUint32
+	}
+	return priorityQueue{
+		wakeQ:   mpmc.NewBoundedWake[Task](int64(config.WakeQSize), wakeCh),
+		spawnQ:  mpmc.NewBoundedWake[Task](int64(config.SpawnQSize), wakeCh),
+		invokeQ: mpmc.NewBoundedWake[func()](int64(config.InvokeQSize), wakeCh),
+		weight:  weight,
+	}
+}
+
+func (q *priorityQueue) isEmpty() bool {
+	return q.invokeQ.IsEmpty() && q.wakeQ.IsEmpty() && q.spawnQ.IsEmpty()
+}
+
+// PriorityStats returns a snapshot of the stats of priority.
+func (r *Reactor) PriorityStats(priority Priority) PriorityStats {
+	if !priority.valid() {
+		return PriorityStats{}
+	}
+	q := &r.queues[priority]
+	return PriorityStats{
+		Invokes:  q.invokes.Load(),
+		Wakes:    q.wakes.Load(),
+		Spawns:   q.spawns.Load(),
+		Deferred: q.deferred.Load(),
+	}
+}
+
+// flushPriority dequeues up to the weight of priority from each of its queues.
+func (r *Reactor) flushPriority(priority Priority) int {
+	q := &r.queues[priority]
+	total := 0
+	if !q.invokeQ.IsEmpty() {
+		count := q.invokeQ.DequeueManyDeref(q.weight, r.onFn)
+		total += count
+		r.invokes.Add(int64(count))
+		q.invokes.Add(int64(count))
+	}
+	if !q.wakeQ.IsEmpty() {
+		count := q.wakeQ.DequeueMany(q.weight, r.onWake)
+		total += count
+		r.wakes.Add(int64(count))
+		q.wakes.Add(int64(count))
+	}
+	if !q.spawnQ.IsEmpty() {
+		count := q.spawnQ.DequeueMany(q.weight, r.onSpawn)
+		total += count
+		r.spawns.Add(int64(count))
+		q.spawns.Add(int64(count))
+	}
+	if total > 0 && !q.isEmpty() {
+		q.deferred.Incr()
+	}
+	return total
+}
@@ -0,0 +1,85 @@
+package reactor
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/moontrade/kirana/pkg/counter"
+)
+
+func TestReactorPriority(t *testing.T) {
+	r, err := NewReactor(Config{Level1Wheel: NewWheel(Millis25)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close(context.Background())
+
+	var (
+		order []Priority
+		done  = new(counter.Counter)
+	)
+	record := func(p Priority) func() {
+		return func() {
+			order = append(order, p)
+			done.Incr()
+		}
+	}
+	// Queue a burst of low and normal priority work before the Reactor starts.
+	for i := 0; i < 100; i++ {
+		if !r.InvokePriority(PriorityLow, record(PriorityLow)) {
+			t.Fatal("low invoke rejected")
+		}
+	}
+	for i := 0; i < 300; i++ {
+		if !r.Invoke(record(PriorityNormal)) {
+			t.Fatal("normal invoke rejected")
+		}
+	}
+	if !r.InvokePriority(PriorityHigh, record(PriorityHigh)) {
+		t.Fatal("high invoke rejected")
+	}
+	if r.InvokePriority(Priority(NumPriorities), func() {}) {
+		t.Fatal("invalid priority accepted")
+	}
+	r.Start()
+	for done.Load() < 401 {
+		runtime.Gosched()
+	}
+
+	if order[0] != PriorityHigh {
+		t.Fatal("expected high priority first, got", order[0])
+	}
+	// Normal is flushed in batches of DefaultPriorityWeightNormal followed by a
+	// batch of low priority work.
+	if order[DefaultPriorityWeightNormal+1] != PriorityLow {
+		t.Fatal("expected low priority batch after the first normal batch, got", order[DefaultPriorityWeightNormal+1])
+	}
+	if s := r.PriorityStats(PriorityNormal); s.Invokes != 300 || s.Deferred == 0 {
+		t.Fatalf("unexpected normal stats %+v", s)
+	}
+	if s := r.PriorityStats(PriorityLow); s.Invokes != 100 || s.Deferred == 0 {
+		t.Fatalf("unexpected low stats %+v", s)
+	}
+	if s := r.PriorityStats(PriorityHigh); s.Invokes != 1 || s.Deferred != 0 {
+		t.Fatalf("unexpected high stats %+v", s)
+	}
+
+	c := new(counter.Counter)
+	task, err := r.SpawnPriority(&SimpleTask{c: c}, PriorityHigh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Priority() != PriorityHigh {
+		t.Fatal("expected high priority task")
+	}
+	for c.Load() == 0 {
+		runtime.Gosched()
+	}
+	if s := r.PriorityStats(PriorityHigh); s.Spawns != 1 {
+		t.Fatalf("unexpected high stats %+v", s)
+	}
+	if _, err = r.SpawnPriority(&SimpleTask{c: c}, Priority(NumPriorities)); err != ErrInvalidPriority {
+		t.Fatal("expected ErrInvalidPriority, got", err)
+	}
+}
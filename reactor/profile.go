@@ -1,22 +1,198 @@
 package reactor
 
 import (
+	"sort"
+	"sync/atomic"
+	"time"
+
 	"github.com/moontrade/kirana/pkg/counter"
 	"github.com/moontrade/kirana/pkg/cow"
+	"github.com/moontrade/kirana/pkg/hashmap"
+	"github.com/moontrade/kirana/pkg/runtimex"
+	"github.com/moontrade/kirana/pkg/timex"
+	"github.com/moontrade/kirana/pkg/util"
+	"github.com/moontrade/kirana/pkg/wyhash"
 )
 
+// maxPanicsBuffer is the number of most recent panics kept per function.
+const maxPanicsBuffer = 8
+
+// FuncStats accumulates the invocations of a Future's Poll method or an invoked func.
 type FuncStats struct {
+	pc           uintptr
 	receiver     string
 	name         string
+	file         string
+	line         int
 	invokes      counter.Counter
 	invokesDur   counter.TimeCounter
+	invokesMax   counter.Counter
+	wakes        counter.Counter
+	intervals    counter.Counter
 	panics       counter.Counter
 	panicsBuffer cow.Slice[error]
 }
 
+func (fs *FuncStats) record(reason PollReason, elapsed int64) {
+	fs.invokes.Incr()
+	fs.invokesDur.Add(elapsed)
+	if fs.invokesMax.Load() < elapsed {
+		fs.invokesMax.Store(elapsed)
+	}
+	switch reason {
+	case ReasonWake:
+		fs.wakes.Incr()
+	case ReasonInterval, ReasonIntervalBehind:
+		fs.intervals.Incr()
+	}
+}
+
+func (fs *FuncStats) panic(err error) {
+	fs.panics.Incr()
+	fs.panicsBuffer.Append(err)
+	if fs.panicsBuffer.Len() > maxPanicsBuffer {
+		fs.panicsBuffer.RemoveAt(0)
+	}
+}
+
+// Snapshot returns a point-in-time copy of the stats.
+func (fs *FuncStats) Snapshot() Func {
+	return Func{
+		Receiver:   fs.receiver,
+		Name:       fs.name,
+		File:       fs.file,
+		Line:       fs.line,
+		Invokes:    fs.invokes.Load(),
+		InvokesDur: time.Duration(fs.invokesDur.Load()),
+		InvokesMax: time.Duration(fs.invokesMax.Load()),
+		Wakes:      fs.wakes.Load(),
+		Intervals:  fs.intervals.Load(),
+		Panics:     fs.panics.Load(),
+		LastPanics: fs.panicsBuffer.Clone(),
+	}
+}
+
+// Func is a snapshot of FuncStats.
 type Func struct {
+	Receiver   string
+	Name       string
+	File       string
+	Line       int
+	Invokes    int64
+	InvokesDur time.Duration
+	InvokesMax time.Duration
+	Wakes      int64
+	Intervals  int64
+	Panics     int64
+	LastPanics []error
+}
+
+// InvokesAvg is the average duration of an invocation.
+func (f *Func) InvokesAvg() time.Duration {
+	if f.Invokes == 0 {
+		return 0
+	}
+	return f.InvokesDur / time.Duration(f.Invokes)
 }
 
-type FuncMap struct{}
+// FuncMap maps the PC of a Future's Poll method wrapper or an invoked func to its FuncStats.
+// The PC of a method wrapper is unique per concrete type so all Tasks polling the same
+// Future type share the same FuncStats.
+type FuncMap struct {
+	m *hashmap.SyncMap[uintptr, *FuncStats]
+}
+
+func NewFuncMap() *FuncMap {
+	return &FuncMap{
+		m: hashmap.NewSyncMap[uintptr, *FuncStats](8, 64, func(key uintptr) uint64 {
+			return wyhash.Uint64(uint64(key))
+		}),
+	}
+}
+
+// Future returns the FuncStats of the future's Poll method.
+func (fm *FuncMap) Future(future Future) *FuncStats {
+	pc := runtimex.FuncToPCUnsafe(PollToPollFnPointer(future))
+	stats, _ := fm.m.GetOrCreate(pc, func(pc uintptr) *FuncStats {
+		stats := &FuncStats{pc: pc}
+		info := runtimex.GetMethodSlow(future, pc, "Poll")
+		if info != nil {
+			stats.receiver = info.Receiver()
+			stats.name = info.Name()
+			stats.file = info.File()
+			stats.line = info.Line()
+		}
+		return stats
+	})
+	return stats
+}
+
+// Func returns the FuncStats of fn.
+func (fm *FuncMap) Func(fn func()) *FuncStats {
+	pc := runtimex.FuncToPC(fn)
+	stats, _ := fm.m.GetOrCreate(pc, func(pc uintptr) *FuncStats {
+		stats := &FuncStats{pc: pc}
+		info := runtimex.GetFuncInfoUnsafe(pc)
+		if info != nil {
+			stats.name = info.Name()
+			stats.file = info.File()
+			stats.line = info.Line()
+		}
+		return stats
+	})
+	return stats
+}
+
+// Snapshot returns all Func snapshots sorted by total invoke duration descending.
+func (fm *FuncMap) Snapshot() []Func {
+	var funcs []Func
+	fm.m.Scan(func(pc uintptr, stats *FuncStats) bool {
+		funcs = append(funcs, stats.Snapshot())
+		return true
+	})
+	sort.Slice(funcs, func(i, j int) bool {
+		return funcs[i].InvokesDur > funcs[j].InvokesDur
+	})
+	return funcs
+}
 
 type ObjectMap struct{}
+
+// poll invokes the Task's Future and attributes the poll to the Task and its FuncStats.
+func (r *Reactor) poll(task *Task, ctx Context) error {
+	task.polls++
+	task.lastPoll = ctx.Time
+	atomic.StoreInt64(&r.pollingSince, ctx.Time)
+	atomic.StoreInt64(&r.polling, task.id)
+	defer atomic.StoreInt64(&r.polling, 0)
+	r.chargePoll(task)
+	stats := task.stats
+	if stats == nil && r.config.PollBudget <= 0 {
+		return task.future.Poll(ctx)
+	}
+	begin := timex.NanoTime()
+	defer func() {
+		elapsed := timex.NanoTime() - begin
+		task.pollsDur += elapsed
+		r.checkBudget(task, ctx.Reason, elapsed)
+		if stats != nil {
+			stats.record(ctx.Reason, elapsed)
+		}
+		if e := recover(); e != nil {
+			if stats != nil {
+				stats.panic(util.PanicToError(e))
+			}
+			panic(e)
+		}
+	}()
+	return task.future.Poll(ctx)
+}
+
+// FuncStats returns a snapshot of the profile of every Future type and invoked func
+// sorted by total duration descending. It is empty unless Config.Profile is set.
+func (r *Reactor) FuncStats() []Func {
+	if r.funcs == nil {
+		return nil
+	}
+	return r.funcs.Snapshot()
+}
@@ -0,0 +1,45 @@
+package reactor
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/moontrade/kirana/pkg/counter"
+)
+
+func TestReactorFuncStats(t *testing.T) {
+	r, err := NewReactor(Config{Level1Wheel: NewWheel(Millis25), Profile: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Start()
+	defer r.Close(context.Background())
+
+	c := new(counter.Counter)
+	task, err := r.SpawnInterval(&SimpleTask{c: c}, time.Millisecond*25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for c.Load() < 3 {
+		runtime.Gosched()
+	}
+
+	var found *Func
+	for _, fn := range r.FuncStats() {
+		fn := fn
+		if fn.Receiver == "SimpleTask" {
+			found = &fn
+		}
+	}
+	if found == nil {
+		t.Fatal("SimpleTask not profiled")
+	}
+	if found.Invokes < 3 || found.Intervals < 2 || found.InvokesDur <= 0 {
+		t.Fatal("unexpected stats", *found)
+	}
+	if task.Polls() < 3 {
+		t.Fatal("expected task polls to be counted, got", task.Polls())
+	}
+}
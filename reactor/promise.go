@@ -0,0 +1,441 @@
+package reactor
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/moontrade/kirana/pkg/spinlock"
+	"github.com/moontrade/kirana/pkg/timex"
+	"github.com/moontrade/kirana/pkg/util"
+)
+
+var (
+	// ErrTimeout is the rejection error of a Promise created by Timeout.
+	ErrTimeout = errors.New("promise timeout")
+	// ErrNoPromises is the rejection error of All or Any given no promises.
+	ErrNoPromises = errors.New("no promises")
+)
+
+const (
+	promisePending  int32 = 0
+	promiseResolved int32 = 1
+	promiseRejected int32 = 2
+)
+
+// Promise is a value that is completed once from any goroutine. Tasks await a Promise
+// from Poll and are woken on their Reactor when it completes.
+//
+//	func (f *Fetch) Poll(ctx reactor.Context) error {
+//		if f.result == nil {
+//			f.result = reactor.Blocking(f.fetch)
+//		}
+//		value, done, err := f.result.Await(ctx)
+//		if !done {
+//			return nil
+//		}
+//		...
+//	}
+type Promise[T any] struct {
+	value     T
+	err       error
+	state     int32
+	waiters   []promiseWaiter
+	callbacks []func(value T, err error)
+	done      chan struct{}
+	mu        spinlock.Mutex
+}
+
+type promiseWaiter struct {
+	task *Task
+	id   int64
+}
+
+// NewPromise creates a pending Promise.
+func NewPromise[T any]() *Promise[T] {
+	return &Promise[T]{}
+}
+
+// Resolved creates a Promise that is already resolved with value.
+func Resolved[T any](value T) *Promise[T] {
+	p := &Promise[T]{}
+	p.Resolve(value)
+	return p
+}
+
+// Rejected creates a Promise that is already rejected with err.
+func Rejected[T any](err error) *Promise[T] {
+	p := &Promise[T]{}
+	p.Reject(err)
+	return p
+}
+
+// Resolve completes the Promise with value. Returns false if already completed.
+func (p *Promise[T]) Resolve(value T) bool {
+	return p.Complete(value, nil)
+}
+
+// Reject completes the Promise with err. Returns false if already completed.
+func (p *Promise[T]) Reject(err error) bool {
+	var zero T
+	if err == nil {
+		err = errors.New("nil rejection")
+	}
+	return p.Complete(zero, err)
+}
+
+// Complete resolves the Promise with value if err is nil, otherwise rejects it with err.
+// Awaiting Tasks are woken and callbacks are invoked on the calling goroutine. Returns
+// false if already completed.
+func (p *Promise[T]) Complete(value T, err error) bool {
+	p.mu.Lock()
+	if p.state != promisePending {
+		p.mu.Unlock()
+		return false
+	}
+	p.value = value
+	p.err = err
+	if err != nil {
+		p.state = promiseRejected
+	} else {
+		p.state = promiseResolved
+	}
+	waiters := p.waiters
+	callbacks := p.callbacks
+	p.waiters = nil
+	p.callbacks = nil
+	if p.done != nil {
+		close(p.done)
+	}
+	p.mu.Unlock()
+
+	for _, waiter := range waiters {
+		// The Task may have been stopped and recycled while waiting.
+		if waiter.task.id == waiter.id {
+			_ = waiter.task.Wake()
+		}
+	}
+	for _, fn := range callbacks {
+		fn(value, err)
+	}
+	return true
+}
+
+// IsDone reports whether the Promise was resolved or rejected.
+func (p *Promise[T]) IsDone() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state != promisePending
+}
+
+// Result returns the value and error of a completed Promise. done is false if
+// the Promise is still pending.
+func (p *Promise[T]) Result() (value T, done bool, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.state == promisePending {
+		return value, false, nil
+	}
+	return p.value, true, p.err
+}
+
+// Await is called from Poll. If the Promise is complete the result is returned with done
+// set to true. Otherwise, the Task is woken once the Promise completes.
+func (p *Promise[T]) Await(ctx Context) (value T, done bool, err error) {
+	return p.AwaitTask(ctx.Task)
+}
+
+// AwaitTask returns the result if the Promise is complete, otherwise task is woken
+// once the Promise completes.
+func (p *Promise[T]) AwaitTask(task *Task) (value T, done bool, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.state != promisePending {
+		return p.value, true, p.err
+	}
+	if task == nil {
+		return value, false, nil
+	}
+	for _, waiter := range p.waiters {
+		if waiter.task == task && waiter.id == task.id {
+			return value, false, nil
+		}
+	}
+	p.waiters = append(p.waiters, promiseWaiter{task: task, id: task.id})
+	return value, false, nil
+}
+
+// OnComplete invokes fn with the result once the Promise completes. fn is invoked on
+// the completing goroutine or immediately if the Promise is already complete.
+func (p *Promise[T]) OnComplete(fn func(value T, err error)) {
+	if fn == nil {
+		return
+	}
+	p.mu.Lock()
+	if p.state == promisePending {
+		p.callbacks = append(p.callbacks, fn)
+		p.mu.Unlock()
+		return
+	}
+	value, err := p.value, p.err
+	p.mu.Unlock()
+	fn(value, err)
+}
+
+// Wait blocks the calling goroutine until the Promise completes or ctx is done. It must
+// never be called from a Reactor goroutine, use Await instead.
+func (p *Promise[T]) Wait(ctx context.Context) (T, error) {
+	p.mu.Lock()
+	if p.state != promisePending {
+		value, err := p.value, p.err
+		p.mu.Unlock()
+		return value, err
+	}
+	if p.done == nil {
+		p.done = make(chan struct{})
+	}
+	done := p.done
+	p.mu.Unlock()
+
+	select {
+	case <-done:
+		value, _, err := p.Result()
+		return value, err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Then returns a Promise completed with the result of fn applied to the resolved value of p.
+// fn is invoked on the goroutine that completes p. Rejections are passed through and a
+// panic in fn rejects the returned Promise.
+func Then[T, R any](p *Promise[T], fn func(value T) (R, error)) *Promise[R] {
+	next := NewPromise[R]()
+	p.OnComplete(func(value T, err error) {
+		if err != nil {
+			next.Reject(err)
+			return
+		}
+		completeThen(next, fn, value)
+	})
+	return next
+}
+
+// ThenOn is like Then except fn is invoked on Reactor r.
+func ThenOn[T, R any](r *Reactor, p *Promise[T], fn func(value T) (R, error)) *Promise[R] {
+	next := NewPromise[R]()
+	p.OnComplete(func(value T, err error) {
+		if err != nil {
+			next.Reject(err)
+			return
+		}
+		if !r.Invoke(func() {
+			completeThen(next, fn, value)
+		}) {
+			if r.IsClosed() {
+				next.Reject(ErrShutdown)
+			} else {
+				next.Reject(ErrQueueFull)
+			}
+		}
+	})
+	return next
+}
+
+// completeThen completes next with the result of fn(value) or rejects it if fn panics.
+func completeThen[T, R any](next *Promise[R], fn func(value T) (R, error), value T) {
+	defer func() {
+		if e := recover(); e != nil {
+			next.Reject(util.PanicToError(e))
+		}
+	}()
+	next.Complete(fn(value))
+}
+
+// All returns a Promise resolved with the values of all promises in order or rejected
+// with the first rejection.
+func All[T any](promises ...*Promise[T]) *Promise[[]T] {
+	all := NewPromise[[]T]()
+	if len(promises) == 0 {
+		all.Resolve(nil)
+		return all
+	}
+	var (
+		values    = make([]T, len(promises))
+		remaining = len(promises)
+		mu        spinlock.Mutex
+	)
+	for i, p := range promises {
+		i := i
+		p.OnComplete(func(value T, err error) {
+			if err != nil {
+				all.Reject(err)
+				return
+			}
+			mu.Lock()
+			values[i] = value
+			remaining--
+			done := remaining == 0
+			mu.Unlock()
+			if done {
+				all.Resolve(values)
+			}
+		})
+	}
+	return all
+}
+
+// Any returns a Promise resolved with the first resolved value of promises or rejected
+// with the last rejection if all are rejected.
+func Any[T any](promises ...*Promise[T]) *Promise[T] {
+	first := NewPromise[T]()
+	if len(promises) == 0 {
+		first.Reject(ErrNoPromises)
+		return first
+	}
+	var (
+		remaining = len(promises)
+		mu        spinlock.Mutex
+	)
+	for _, p := range promises {
+		p.OnComplete(func(value T, err error) {
+			if err == nil {
+				first.Resolve(value)
+				return
+			}
+			mu.Lock()
+			remaining--
+			done := remaining == 0
+			mu.Unlock()
+			if done {
+				first.Reject(err)
+			}
+		})
+	}
+	return first
+}
+
+// Timeout returns a Promise completed with the result of p or rejected with ErrTimeout
+// if p does not complete within timeout. The timeout is driven by the timing wheels of
+// Reactor r and has the precision of its tick duration. Timeouts beyond the Level3
+// wheel fall back to the Reactor's timer heap.
+func Timeout[T any](r *Reactor, p *Promise[T], timeout time.Duration) *Promise[T] {
+	next := NewPromise[T]()
+	timer := &promiseTimer{
+		after:  timeout,
+		isDone: next.IsDone,
+		expire: func() {
+			var zero T
+			next.Complete(zero, ErrTimeout)
+		},
+	}
+	task, err := r.Spawn(timer)
+	if err != nil {
+		next.Reject(err)
+		return next
+	}
+	p.OnComplete(func(value T, err error) {
+		if next.Complete(value, err) {
+			// Stop the timer.
+			_ = task.Wake()
+		}
+	})
+	return next
+}
+
+// promiseTimer is the Future behind Timeout.
+type promiseTimer struct {
+	after    time.Duration
+	deadline int64
+	isDone   func() bool
+	expire   func()
+}
+
+func (t *promiseTimer) Poll(ctx Context) error {
+	if t.isDone() {
+		ctx.Stop()
+		return nil
+	}
+	if ctx.Reason == ReasonStart {
+		t.deadline = ctx.Time + int64(t.after)
+		ctx.WakeAfter(t.after)
+		return nil
+	}
+	if remaining := t.deadline - ctx.Time; remaining > 0 {
+		ctx.WakeAfter(time.Duration(remaining))
+		return nil
+	}
+	t.expire()
+	ctx.Stop()
+	return nil
+}
+
+// Blocking runs fn on the global BlockingPool and returns a Promise of its result.
+func Blocking[T any](fn func() (T, error)) *Promise[T] {
+	return BlockingOn(blocking, fn)
+}
+
+// BlockingOn runs fn on pool and returns a Promise of its result. A panic in fn
+// rejects the Promise as does the pool's RejectionPolicy.
+func BlockingOn[T any](pool *BlockingPool, fn func() (T, error)) *Promise[T] {
+	p := NewPromise[T]()
+	if pool == nil {
+		p.Reject(errors.New("blocking pool not initialized"))
+		return p
+	}
+	if err := pool.submit(func() {
+		defer func() {
+			if e := recover(); e != nil {
+				p.Reject(util.PanicToError(e))
+			}
+		}()
+		p.Complete(fn())
+	}, 0, func(err error) { p.Reject(err) }, pool.config.EnqueueTimeout); err != nil {
+		p.Reject(err)
+	}
+	return p
+}
+
+// BlockingTimeout runs fn on pool with a context that expires after timeout. The
+// Promise is rejected with ErrTimeout if fn hasn't completed by then including
+// while still queued in which case fn is never run.
+func BlockingTimeout[T any](pool *BlockingPool, timeout time.Duration, fn func(ctx context.Context) (T, error)) *Promise[T] {
+	p := NewPromise[T]()
+	if pool == nil {
+		p.Reject(errors.New("blocking pool not initialized"))
+		return p
+	}
+	if timeout <= 0 {
+		p.Reject(ErrTimeout)
+		return p
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	timer := time.AfterFunc(timeout, func() {
+		p.Reject(ErrTimeout)
+	})
+	deadline := timex.NanoTime() + int64(timeout)
+	if err := pool.submit(func() {
+		defer func() {
+			timer.Stop()
+			cancel()
+			if e := recover(); e != nil {
+				p.Reject(util.PanicToError(e))
+			}
+		}()
+		value, err := fn(ctx)
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			err = ErrTimeout
+		}
+		p.Complete(value, err)
+	}, deadline, func(err error) {
+		timer.Stop()
+		cancel()
+		p.Reject(err)
+	}, pool.config.EnqueueTimeout); err != nil {
+		timer.Stop()
+		cancel()
+		p.Reject(err)
+	}
+	return p
+}
@@ -0,0 +1,117 @@
+package reactor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type awaitTask struct {
+	promise *Promise[int]
+	result  *Promise[int]
+}
+
+func (a *awaitTask) Poll(ctx Context) error {
+	value, done, err := a.promise.Await(ctx)
+	if !done {
+		return nil
+	}
+	a.result.Complete(value, err)
+	ctx.Stop()
+	return nil
+}
+
+func TestPromiseAwait(t *testing.T) {
+	r, err := NewReactor(Config{Level1Wheel: NewWheel(Millis25)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Start()
+	defer r.Close(context.Background())
+
+	pool := NewBlockingPool(1, 64)
+	defer pool.Close()
+
+	task := &awaitTask{
+		promise: BlockingOn(pool, func() (int, error) {
+			time.Sleep(time.Millisecond * 10)
+			return 42, nil
+		}),
+		result: NewPromise[int](),
+	}
+	if _, err = r.Spawn(task); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	value, err := task.result.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != 42 {
+		t.Fatal("expected 42, got", value)
+	}
+}
+
+func TestPromiseCombinators(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	a, b := NewPromise[int](), NewPromise[int]()
+	all := All(a, b)
+	sum := Then(all, func(values []int) (int, error) {
+		return values[0] + values[1], nil
+	})
+	b.Resolve(2)
+	a.Resolve(1)
+	if value, err := sum.Wait(ctx); err != nil || value != 3 {
+		t.Fatal("expected 3, got", value, err)
+	}
+
+	failed := errors.New("failed")
+	if _, err := All(Resolved(1), Rejected[int](failed)).Wait(ctx); err != failed {
+		t.Fatal("expected rejection, got", err)
+	}
+	if value, err := Any(Rejected[int](failed), Resolved(7)).Wait(ctx); err != nil || value != 7 {
+		t.Fatal("expected 7, got", value, err)
+	}
+	if _, err := Any(Rejected[int](failed), Rejected[int](failed)).Wait(ctx); err != failed {
+		t.Fatal("expected rejection, got", err)
+	}
+}
+
+func TestPromiseThenPanic(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	p := NewPromise[int]()
+	next := Then(p, func(value int) (int, error) {
+		panic("boom")
+	})
+	p.Resolve(1)
+	if _, err := next.Wait(ctx); err == nil || err.Error() != "boom" {
+		t.Fatal("expected panic rejection, got", err)
+	}
+}
+
+func TestPromiseTimeout(t *testing.T) {
+	r, err := NewReactor(Config{Level1Wheel: NewWheel(Millis25)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Start()
+	defer r.Close(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if _, err = Timeout(r, NewPromise[int](), time.Millisecond*50).Wait(ctx); err != ErrTimeout {
+		t.Fatal("expected ErrTimeout, got", err)
+	}
+	p := NewPromise[int]()
+	timeout := Timeout(r, p, time.Second)
+	p.Resolve(1)
+	if value, err := timeout.Wait(ctx); err != nil || value != 1 {
+		t.Fatal("expected 1, got", value, err)
+	}
+}
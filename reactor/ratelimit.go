@@ -0,0 +1,326 @@
+package reactor
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/moontrade/kirana/pkg/counter"
+	"github.com/moontrade/kirana/pkg/mpmc"
+)
+
+// tokenScale is the fixed point scale of tokens which allows fractional rates.
+const tokenScale = 1_000_000
+
+// DefaultRateLimiterWaiters is the capacity of the queue of Tasks on other Reactors
+// waiting for permits. Tasks that do not fit fall back to waking on the next tick.
+const DefaultRateLimiterWaiters = 1024
+
+var ErrInvalidRate = errors.New("invalid rate")
+
+// RateLimiter hands out permits at a fixed rate. Limiters are refilled by the tick of
+// the Reactor they are created on and permits can be acquired from any goroutine or
+// Reactor with atomics.
+type RateLimiter interface {
+	// TryAcquire takes n permits if they are available.
+	TryAcquire(n int) bool
+	// Acquire is TryAcquire that wakes task once n permits may be available when it
+	// returns false. The Task should call Acquire again when woken.
+	Acquire(task *Task, n int) bool
+	// Available returns the number of permits that can be acquired now.
+	Available() int
+	// Close stops refilling the RateLimiter and wakes its waiters.
+	Close() error
+}
+
+// RateLimiterStats is a snapshot of the stats of a RateLimiter.
+type RateLimiterStats struct {
+	Acquired int64
+	Rejected int64
+	Waits    int64
+	Wakes    int64
+}
+
+// limiter is a RateLimiter refilled by its Reactor's tick.
+type limiter interface {
+	refill(now int64)
+}
+
+type rateWaiter struct {
+	task *Task
+	id   int64
+	n    int
+}
+
+// rateLimiter is the waiter and stats bookkeeping shared by the RateLimiters.
+type rateLimiter struct {
+	reactor *Reactor
+	// waiters is only accessed on the Reactor goroutine and remote holds the waiters
+	// from other goroutines until the next tick.
+	waiters  []rateWaiter
+	remote   *mpmc.Bounded[rateWaiter]
+	closed   int64
+	acquired counter.Counter
+	rejected counter.Counter
+	waits    counter.Counter
+	wakes    counter.Counter
+}
+
+func (l *rateLimiter) init(r *Reactor, self limiter) {
+	l.reactor = r
+	l.remote = mpmc.NewBounded[rateWaiter](DefaultRateLimiterWaiters)
+	r.addLimiter(self)
+}
+
+func (l *rateLimiter) IsClosed() bool { return atomic.LoadInt64(&l.closed) != 0 }
+
+// wait registers task to be woken once n permits may be available.
+func (l *rateLimiter) wait(task *Task, n int) {
+	l.rejected.Incr()
+	if task == nil {
+		return
+	}
+	l.waits.Incr()
+	if l.reactor.CheckGID() {
+		l.waiters = append(l.waiters, rateWaiter{task: task, id: task.id, n: n})
+	} else if !l.remote.Enqueue(&rateWaiter{task: task, id: task.id, n: n}) {
+		_ = task.WakeAfter(l.reactor.tickDur)
+	}
+}
+
+// wakeWaiters wakes waiters in order while there are permits for them. The permits
+// are not reserved and a woken Task may need to wait again.
+func (l *rateLimiter) wakeWaiters(available int) {
+	l.remote.DequeueMany(l.remote.Cap(), func(w *rateWaiter) {
+		l.waiters = append(l.waiters, *w)
+	})
+	i := 0
+	for ; i < len(l.waiters); i++ {
+		w := l.waiters[i]
+		// The Task may have been stopped and recycled while waiting.
+		if w.task.id != w.id {
+			l.waiters[i] = rateWaiter{}
+			continue
+		}
+		if w.n > available && !l.IsClosed() {
+			break
+		}
+		available -= w.n
+		if w.task.Wake() == nil {
+			l.wakes.Incr()
+		}
+		l.waiters[i] = rateWaiter{}
+	}
+	if i > 0 {
+		l.waiters = append(l.waiters[:0], l.waiters[i:]...)
+	}
+}
+
+func (l *rateLimiter) close(self limiter) error {
+	if !atomic.CompareAndSwapInt64(&l.closed, 0, 1) {
+		return os.ErrClosed
+	}
+	r := l.reactor
+	if r.CheckGID() {
+		r.removeLimiter(self)
+		l.wakeWaiters(0)
+	} else {
+		r.Invoke(func() {
+			r.removeLimiter(self)
+			l.wakeWaiters(0)
+		})
+	}
+	return nil
+}
+
+func (l *rateLimiter) Stats() RateLimiterStats {
+	return RateLimiterStats{
+		Acquired: l.acquired.Load(),
+		Rejected: l.rejected.Load(),
+		Waits:    l.waits.Load(),
+		Wakes:    l.wakes.Load(),
+	}
+}
+
+// TokenBucket is a RateLimiter that holds up to burst tokens refilled at rate tokens
+// per second.
+type TokenBucket struct {
+	rateLimiter
+	tokens  int64
+	burst   int64
+	perNano float64
+	last    int64
+}
+
+// NewTokenBucket creates a full TokenBucket refilled by the tick of r at rate tokens
+// per second up to burst.
+func NewTokenBucket(r *Reactor, rate float64, burst int) (*TokenBucket, error) {
+	if rate <= 0 || burst < 1 {
+		return nil, ErrInvalidRate
+	}
+	b := &TokenBucket{
+		tokens:  int64(burst) * tokenScale,
+		burst:   int64(burst) * tokenScale,
+		perNano: rate * tokenScale / float64(time.Second),
+		last:    r.now,
+	}
+	b.init(r, b)
+	return b, nil
+}
+
+func (b *TokenBucket) TryAcquire(n int) bool {
+	if n <= 0 {
+		return true
+	}
+	need := int64(n) * tokenScale
+	for !b.IsClosed() {
+		tokens := atomic.LoadInt64(&b.tokens)
+		if tokens < need {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&b.tokens, tokens, tokens-need) {
+			b.acquired.Add(int64(n))
+			return true
+		}
+	}
+	return false
+}
+
+func (b *TokenBucket) Acquire(task *Task, n int) bool {
+	if b.TryAcquire(n) {
+		return true
+	}
+	b.wait(task, n)
+	return false
+}
+
+func (b *TokenBucket) Available() int {
+	return int(atomic.LoadInt64(&b.tokens) / tokenScale)
+}
+
+func (b *TokenBucket) Close() error { return b.close(b) }
+
+func (b *TokenBucket) refill(now int64) {
+	elapsed := now - b.last
+	if elapsed <= 0 {
+		return
+	}
+	b.last = now
+	add := int64(float64(elapsed) * b.perNano)
+	for {
+		tokens := atomic.LoadInt64(&b.tokens)
+		next := tokens + add
+		if next > b.burst {
+			next = b.burst
+		}
+		if atomic.CompareAndSwapInt64(&b.tokens, tokens, next) {
+			break
+		}
+	}
+	if len(b.waiters) > 0 || !b.remote.IsEmpty() {
+		b.wakeWaiters(b.Available())
+	}
+}
+
+// SlidingWindow is a RateLimiter that allows up to limit permits in any window.
+// Permits are counted in slots of the Reactor's tick and expire no earlier than the
+// window after they were acquired.
+type SlidingWindow struct {
+	rateLimiter
+	limit int64
+	// used is the count in the window including pending.
+	used    int64
+	pending int64
+	slots   []int64
+	current int
+}
+
+// NewSlidingWindow creates a SlidingWindow that is advanced by the tick of r.
+func NewSlidingWindow(r *Reactor, limit int, window time.Duration) (*SlidingWindow, error) {
+	if limit < 1 || window <= 0 {
+		return nil, ErrInvalidRate
+	}
+	// Permits are moved into the current slot on the tick after they were acquired
+	// which needs an extra slot to not expire early.
+	n := int((window+r.tickDur-1)/r.tickDur) + 1
+	w := &SlidingWindow{
+		limit: int64(limit),
+		slots: make([]int64, n),
+	}
+	w.init(r, w)
+	return w, nil
+}
+
+func (w *SlidingWindow) TryAcquire(n int) bool {
+	if n <= 0 {
+		return true
+	}
+	for !w.IsClosed() {
+		used := atomic.LoadInt64(&w.used)
+		if used+int64(n) > w.limit {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&w.used, used, used+int64(n)) {
+			atomic.AddInt64(&w.pending, int64(n))
+			w.acquired.Add(int64(n))
+			return true
+		}
+	}
+	return false
+}
+
+func (w *SlidingWindow) Acquire(task *Task, n int) bool {
+	if w.TryAcquire(n) {
+		return true
+	}
+	w.wait(task, n)
+	return false
+}
+
+func (w *SlidingWindow) Available() int {
+	return int(w.limit - atomic.LoadInt64(&w.used))
+}
+
+func (w *SlidingWindow) Close() error { return w.close(w) }
+
+func (w *SlidingWindow) refill(now int64) {
+	w.slots[w.current] += atomic.SwapInt64(&w.pending, 0)
+	w.current++
+	if w.current == len(w.slots) {
+		w.current = 0
+	}
+	if expired := w.slots[w.current]; expired > 0 {
+		w.slots[w.current] = 0
+		atomic.AddInt64(&w.used, -expired)
+	}
+	if len(w.waiters) > 0 || !w.remote.IsEmpty() {
+		w.wakeWaiters(w.Available())
+	}
+}
+
+func (r *Reactor) addLimiter(l limiter) {
+	if r.CheckGID() {
+		r.limiters = append(r.limiters, l)
+		return
+	}
+	r.Invoke(func() {
+		r.limiters = append(r.limiters, l)
+	})
+}
+
+func (r *Reactor) removeLimiter(l limiter) {
+	for i, other := range r.limiters {
+		if other == l {
+			r.limiters = append(r.limiters[:i], r.limiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// refillLimiters advances the RateLimiters of the Reactor by a tick.
+func (r *Reactor) refillLimiters(now int64) {
+	for _, l := range r.limiters {
+		l.refill(now)
+	}
+}
@@ -0,0 +1,68 @@
+package reactor
+
+import (
+	"testing"
+	"time"
+)
+
+type permitTask struct {
+	limiter RateLimiter
+	granted int
+}
+
+func (p *permitTask) Poll(ctx Context) error {
+	for p.limiter.Acquire(ctx.Task, 1) {
+		p.granted++
+	}
+	return nil
+}
+
+func TestRateLimiters(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		limiter func(r *Reactor) (RateLimiter, error)
+		// granted is the expected number of permits after each tick starting at 0.
+		granted []int
+	}{
+		{"token bucket", func(r *Reactor) (RateLimiter, error) {
+			// A token per 25ms tick with a burst of 2.
+			return NewTokenBucket(r, 40, 2)
+		}, []int{2, 3, 4, 5, 6, 7}},
+		{"sliding window", func(r *Reactor) (RateLimiter, error) {
+			return NewSlidingWindow(r, 3, time.Millisecond*100)
+		}, []int{3, 3, 3, 3, 3, 6}},
+	} {
+		clock := NewManualClock(time.Millisecond * 25)
+		r, err := NewReactor(Config{
+			Level1Wheel: NewWheel(Millis25),
+			Clock:       clock,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		limiter, err := tc.limiter(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		task := &permitTask{limiter: limiter}
+		if _, err = r.Spawn(task); err != nil {
+			t.Fatal(err)
+		}
+		r.onWakeMessage(0)
+		for tick, want := range tc.granted {
+			if tick > 0 {
+				clock.Advance(time.Millisecond * 25)
+				r.onWakeMessage(int64(tick))
+			}
+			if task.granted != want {
+				t.Fatalf("%s: tick %d expected %d permits, got %d", tc.name, tick, want, task.granted)
+			}
+		}
+		if err = limiter.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if limiter.TryAcquire(1) {
+			t.Fatalf("%s: expected closed limiter to reject", tc.name)
+		}
+	}
+}
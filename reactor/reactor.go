@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/moontrade/kirana/pkg/counter"
 	"github.com/moontrade/kirana/pkg/hashmap"
+	"github.com/moontrade/kirana/pkg/histogram"
 	"github.com/moontrade/kirana/pkg/mpmc"
 	"github.com/moontrade/kirana/pkg/pmath"
 	"github.com/moontrade/kirana/pkg/runtimex"
@@ -14,6 +15,7 @@ import (
 	"github.com/moontrade/kirana/pkg/wyhash"
 	"github.com/panjf2000/ants"
 	"math"
+	"os"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -54,6 +56,23 @@ type Stats struct {
 	level3TicksDurMin  counter.Counter
 	level3TicksDurMax  counter.Counter
 	pidSwitches        counter.Counter
+	rebalances         counter.Counter
+	migrations         counter.Counter
+	panics             counter.Counter
+	pollErrors         counter.Counter
+	restarts           counter.Counter
+	missedTicks        counter.Counter
+	timersAdded        counter.Counter
+	timersFired        counter.Counter
+	timersStopped      counter.Counter
+	timersLagDur       counter.TimeCounter
+	overBudget         counter.Counter
+	overBudgetDur      counter.TimeCounter
+	budgetDeferred     counter.Counter
+	spins              counter.Counter
+	spinsDur           counter.TimeCounter
+	parks              counter.Counter
+	parksDur           counter.TimeCounter
 }
 
 type Runnable interface{}
@@ -61,6 +80,15 @@ type Runnable interface{}
 var (
 	ErrQueueFull = errors.New("queue full")
 	ErrStop      = errors.New("stop")
+	// ErrShutdown is the CloseEvent Reason delivered to live Tasks when their Reactor is closed.
+	ErrShutdown = errors.New("reactor shutdown")
+)
+
+const (
+	reactorNew     int64 = 0
+	reactorRunning int64 = 1
+	reactorClosing int64 = 2
+	reactorClosed  int64 = 3
 )
 
 const (
@@ -69,6 +97,20 @@ const (
 	DefaultSpawnQueueSize  = 1024 * 1
 )
 
+const (
+	// DefaultRebalanceThreshold is the tick CPU ratio that is considered overloaded.
+	DefaultRebalanceThreshold = 0.75
+	// DefaultRebalanceTicks is the number of consecutive overloaded ticks before rebalancing.
+	DefaultRebalanceTicks = 8
+	// DefaultRebalanceMax is the max number of tasks migrated per rebalance.
+	DefaultRebalanceMax = 64
+
+	// loadScale is the fixed point scale of the load EWMA.
+	loadScale = 1_000_000
+	// loadSmoothing is the EWMA divisor. Each tick moves the load 1/8th of the way to the sample.
+	loadSmoothing = 8
+)
+
 type Config struct {
 	Name         string
 	Level1Wheel  Wheel
@@ -78,6 +120,53 @@ type Config struct {
 	WakeQSize    int
 	SpawnQSize   int
 	LockOSThread bool
+	// RebalanceThreshold is the tick CPU ratio (tick time / tick duration) that once
+	// sustained for RebalanceTicks migrates interval tasks to the least loaded Reactor.
+	// 0 uses DefaultRebalanceThreshold and a negative value disables rebalancing.
+	RebalanceThreshold float64
+	RebalanceTicks     int
+	RebalanceMax       int
+	// Profile attributes poll durations, wakes and panics to each Future type
+	// and invoked func. See Reactor.FuncStats.
+	Profile bool
+	// PanicHandler decides how a panicking Task is handled. Defaults to FaultStop.
+	PanicHandler PanicHandler
+	// ErrorHandler decides how a Task returning an error is handled. Defaults to FaultIgnore.
+	ErrorHandler ErrorHandler
+	// RestartBackoffMin is the backoff of the first FaultRestart which doubles on each
+	// subsequent restart up to RestartBackoffMax.
+	RestartBackoffMin time.Duration
+	RestartBackoffMax time.Duration
+	// MaxRestarts is the max number of restarts of a Task before it is stopped. 0 is unlimited.
+	MaxRestarts int
+	// Clock drives the Reactor's ticks and time. Defaults to the global Ticker.
+	// Use a ManualClock for deterministic tests.
+	Clock Clock
+	// Epoch is the wall-clock time of the Clock's current time which maps Clock time
+	// to wall-clock time for SpawnSchedule. Defaults to time.Now().
+	Epoch time.Time
+	// PriorityWeights is the max number of items dequeued from each queue of a Priority
+	// before PriorityHigh is flushed again. PriorityHigh is unbounded unless set.
+	PriorityWeights [NumPriorities]int
+	// SpinMode decides whether the Reactor busy-polls its queues instead of parking
+	// on its wake channel. SpinBudget is the idle window of SpinHybrid before parking.
+	SpinMode   SpinMode
+	SpinBudget time.Duration
+	// CatchUp decides how interval tasks are polled for ticks missed while the
+	// Reactor was behind. Defaults to CatchUpReplay.
+	CatchUp CatchUpPolicy
+	// PollBudget is the max duration of a single poll. A Task whose poll exceeds it
+	// is reported to BudgetHandler and its wakes are deferred to the next tick.
+	// 0 disables the budget. Overruns are always counted in the Reactor stats and per
+	// Task. BudgetHandler defaults to a rate limited warning logged on the BlockingPool.
+	PollBudget    time.Duration
+	BudgetHandler BudgetHandler
+	// MaxPollsPerTick is the max number of polls of a Task in a tick before its
+	// wakes are deferred to the next tick. 0 is unlimited.
+	MaxPollsPerTick int
+	// CPU is the set of CPUs the Reactor's OS thread is pinned to which implies
+	// LockOSThread. See AutoAffinity.
+	CPU []int
 }
 
 // Reactor runs all tasks on a single goroutine. It has an optimized timing mechanism
@@ -94,13 +183,10 @@ type Reactor struct {
 	now  int64
 	size counter.Counter
 	//currentTick    counter.Counter
-	idCounter      counter.Counter
 	state          int64
 	config         Config
-	wakeQ          *mpmc.BoundedWake[Task]
 	wakeListQ      *mpmc.BoundedWake[WakeList]
-	spawnQ         *mpmc.BoundedWake[Task]
-	invokeQ        *mpmc.BoundedWake[func()]
+	queues         [NumPriorities]priorityQueue
 	timer          chan Tick
 	lastTick       int64
 	currentTick    int64
@@ -119,6 +205,25 @@ type Reactor struct {
 	pid            int32
 	gid            uint64
 	wg             sync.WaitGroup
+	load           counter.Counter
+	overloaded     int
+	rebalanceAt    int64
+	funcs          *FuncMap
+	clock          Clock
+	processed      int64
+	tickHist       *histogram.Histogram
+	affinityErr    atomic.Pointer[error]
+	catchingUp     bool
+	timers         timerHeap
+	hrTimer        *time.Timer
+	hrTimerAt      int64
+	epoch          time.Time
+	epochNano      int64
+	polling        int64
+	pollingSince   int64
+	budgetWarnAt   int64
+	limiters       []limiter
+	closeMu        sync.Mutex
 }
 
 func NewReactor(config Config) (*Reactor, error) {
@@ -154,6 +259,33 @@ func NewReactor(config Config) (*Reactor, error) {
 		return nil, fmt.Errorf("minutes Tick not evenly divisible by millisecond Tick: %s mod %s = %s",
 			config.Level3Wheel.tickDur, config.Level1Wheel.tickDur, config.Level3Wheel.tickDur%config.Level1Wheel.tickDur)
 	}
+	if config.RebalanceThreshold == 0 {
+		config.RebalanceThreshold = DefaultRebalanceThreshold
+	}
+	if config.RebalanceTicks <= 0 {
+		config.RebalanceTicks = DefaultRebalanceTicks
+	}
+	if config.RebalanceMax <= 0 {
+		config.RebalanceMax = DefaultRebalanceMax
+	}
+	if config.PriorityWeights[PriorityNormal] <= 0 {
+		config.PriorityWeights[PriorityNormal] = DefaultPriorityWeightNormal
+	}
+	if config.PriorityWeights[PriorityLow] <= 0 {
+		config.PriorityWeights[PriorityLow] = DefaultPriorityWeightLow
+	}
+	if config.SpinMode == SpinHybrid && config.SpinBudget <= 0 {
+		config.SpinBudget = DefaultSpinBudget
+	}
+	if config.RestartBackoffMin <= 0 {
+		config.RestartBackoffMin = DefaultRestartBackoffMin
+	}
+	if config.RestartBackoffMax < config.RestartBackoffMin {
+		config.RestartBackoffMax = DefaultRestartBackoffMax
+		if config.RestartBackoffMax < config.RestartBackoffMin {
+			config.RestartBackoffMax = config.RestartBackoffMin
+		}
+	}
 	wakeCh := make(chan int64, 1)
 	ctx, cancel := context.WithCancel(context.Background())
 	w := &Reactor{
@@ -166,15 +298,32 @@ func NewReactor(config Config) (*Reactor, error) {
 		ticksPerLevel3: int64(config.Level3Wheel.tickDur / config.Level1Wheel.tickDur),
 		wakeCh:         wakeCh,
 		tasks:          hashmap.NewSyncMap[int64, *Task](8, 1024, wyhash.Int64),
-		wakeQ:          mpmc.NewBoundedWake[Task](int64(config.WakeQSize), wakeCh),
 		wakeListQ:      mpmc.NewBoundedWake[WakeList](int64(config.WakeQSize), wakeCh),
-		spawnQ:         mpmc.NewBoundedWake[Task](int64(config.SpawnQSize), wakeCh),
-		invokeQ:        mpmc.NewBoundedWake[func()](int64(config.InvokeQSize), wakeCh),
 		timer:          make(chan Tick, 1),
+		tickHist:       histogram.New(histogram.Durations()...),
 		ctx:            ctx,
 		cancel:         cancel,
 	}
-	w.id = reactors.AppendIndex(w)
+	for p := range w.queues {
+		w.queues[p] = newPriorityQueue(&config, config.PriorityWeights[p], wakeCh)
+	}
+	if config.Profile {
+		w.funcs = NewFuncMap()
+	}
+	if config.Clock != nil {
+		w.clock = config.Clock
+		w.now = w.clock.Now()
+		w.epoch, w.epochNano = config.Epoch, w.now
+		if w.epoch.IsZero() {
+			w.epoch = time.Now()
+		}
+	}
+	if config.RebalanceThreshold > 0 {
+		w.rebalanceAt = int64(config.RebalanceThreshold * loadScale)
+	}
+	// IDs are never reused since TaskSets key their WakeLists by Reactor ID.
+	w.id = int(reactorIDs.Incr() - 1)
+	reactors.Append(w)
 	return w, nil
 }
 
@@ -184,19 +333,123 @@ func (r *Reactor) CheckGID() bool {
 
 func (r *Reactor) ID() int { return r.id }
 
+func (r *Reactor) Name() string { return r.config.Name }
+
 func (r *Reactor) Now() int64 { return r.now }
 
+// Load returns the smoothed tick CPU ratio which is the fraction of the tick duration
+// spent processing ticks.
+func (r *Reactor) Load() float64 {
+	return float64(r.load.Load()) / loadScale
+}
+
 func (r *Reactor) SnapshotStats() Stats {
 	return r.Stats
 }
 
+// LiveStats returns the Stats that are being updated. Fields must be read atomically.
+func (r *Reactor) LiveStats() *Stats {
+	return &r.Stats
+}
+
+// AffinityErr returns the error pinning the Reactor's thread to Config.CPU if any.
+func (r *Reactor) AffinityErr() error { return loadErr(&r.affinityErr) }
+
+// TickHistogram returns the histogram of tick durations in nanoseconds.
+func (r *Reactor) TickHistogram() *histogram.Histogram {
+	return r.tickHist
+}
+
 func (r *Reactor) Start() {
-	if !atomic.CompareAndSwapInt64(&r.state, 0, 1) {
+	if !atomic.CompareAndSwapInt64(&r.state, reactorNew, reactorRunning) {
 		return
 	}
+	r.wg.Add(1)
 	go r.run()
 }
 
+// IsClosed reports whether Close has been called.
+func (r *Reactor) IsClosed() bool {
+	return atomic.LoadInt64(&r.state) >= reactorClosing
+}
+
+// Close stops the Reactor from accepting new spawns, invokes and wakes, drains
+// the queues and delivers PollClose with ErrShutdown to every live Task. The
+// TickListener is unregistered and the Reactor is removed from the global
+// reactors. Close waits for the event loop to exit or ctx to be done.
+func (r *Reactor) Close(ctx context.Context) error {
+	var state int64
+	for {
+		state = atomic.LoadInt64(&r.state)
+		if state >= reactorClosing {
+			return os.ErrClosed
+		}
+		if atomic.CompareAndSwapInt64(&r.state, state, reactorClosing) {
+			break
+		}
+	}
+	reactors.Remove(func(elem *Reactor) bool {
+		return elem == r
+	})
+	if state == reactorNew {
+		// Event loop was never started. Drain on the caller.
+		r.shutdown()
+		r.cancel()
+		return nil
+	}
+	r.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// shutdown drains the queues and stops all live tasks. The state is set to
+// reactorClosed before draining so an enqueue racing with Close either lands before
+// the drain or observes reactorClosed and drains itself with drainLate.
+func (r *Reactor) shutdown() {
+	r.closeMu.Lock()
+	defer r.closeMu.Unlock()
+	atomic.StoreInt64(&r.state, reactorClosed)
+	r.drain()
+}
+
+// drain flushes the queues, stops all live tasks with ErrShutdown and stops the timers.
+func (r *Reactor) drain() {
+	r.now = r.nanotime()
+	for r.flushQueues() > 0 {
+		r.now = r.nanotime()
+	}
+	var tasks []*Task
+	r.tasks.Scan(func(id int64, task *Task) bool {
+		tasks = append(tasks, task)
+		return true
+	})
+	for _, task := range tasks {
+		r.stopTask(r.now, task, ErrShutdown)
+	}
+	r.stopTimers()
+}
+
+// drainLate is called after enqueueing. Work enqueued after the drain of shutdown
+// is drained on the calling goroutine so spawned Tasks still receive PollClose.
+func (r *Reactor) drainLate() {
+	if atomic.LoadInt64(&r.state) != reactorClosed {
+		return
+	}
+	r.closeMu.Lock()
+	defer r.closeMu.Unlock()
+	r.drain()
+}
+
 func (r *Reactor) Duration(ticks int64) time.Duration {
 	return r.tickDur * time.Duration(ticks)
 }
@@ -219,7 +472,11 @@ func (r *Reactor) Wake(task *Task) error {
 	if reactor != r {
 		return reactor.Wake(task)
 	}
-	r.wakeQ.Enqueue(task)
+	if r.IsClosed() {
+		return os.ErrClosed
+	}
+	r.queues[task.priority].wakeQ.Enqueue(task)
+	r.drainLate()
 	return nil
 }
 
@@ -238,11 +495,14 @@ func (r *Reactor) WakeAfter(task *Task, after time.Duration) error {
 	if reactor != r {
 		return reactor.Wake(task)
 	}
-	if !r.wakeQ.Enqueue(task) {
+	if r.IsClosed() {
+		return os.ErrClosed
+	}
+	if !r.queues[task.priority].wakeQ.Enqueue(task) {
 		return ErrQueueFull
-	} else {
-		return nil
 	}
+	r.drainLate()
+	return nil
 }
 
 func (r *Reactor) wakeList(list *WakeList) error {
@@ -255,25 +515,76 @@ func (r *Reactor) wakeList(list *WakeList) error {
 	if list.reactor != r {
 		return list.reactor.wakeList(list)
 	}
+	if r.IsClosed() {
+		// Nothing left to wake.
+		return nil
+	}
 	if !r.wakeListQ.Enqueue(list) {
 		return ErrQueueFull
-	} else {
-		return nil
 	}
+	r.drainLate()
+	return nil
+}
+
+// Stop stops task on its Reactor and delivers reason to PollClose.
+func (r *Reactor) Stop(task *Task, reason any) error {
+	if task == nil {
+		return errors.New("task is nil")
+	}
+	reactor := task.reactor
+	if reactor == nil {
+		return errors.New("task is not scheduled")
+	}
+	if reactor != r {
+		return reactor.Stop(task, reason)
+	}
+	if r.IsClosed() {
+		return os.ErrClosed
+	}
+	id := task.id
+	if !r.Invoke(func() {
+		if task.reactor != r {
+			// Migrated to another Reactor.
+			if task.reactor != nil {
+				_ = task.reactor.Stop(task, reason)
+			}
+			return
+		}
+		if task.id != id || task.stop {
+			return
+		}
+		r.stopTask(r.now, task, reason)
+	}) {
+		return ErrQueueFull
+	}
+	return nil
 }
 
 func (r *Reactor) Invoke(fn func()) bool {
-	if fn == nil {
+	return r.InvokePriority(PriorityNormal, fn)
+}
+
+// InvokePriority enqueues fn to be invoked on the Reactor goroutine at priority.
+func (r *Reactor) InvokePriority(priority Priority, fn func()) bool {
+	if fn == nil || !priority.valid() || r.IsClosed() {
+		return false
+	}
+	if !r.queues[priority].invokeQ.EnqueueUnsafeTimeout(runtimex.FuncToPointer(fn), time.Second*5) {
 		return false
 	}
-	return r.invokeQ.EnqueueUnsafeTimeout(runtimex.FuncToPointer(fn), time.Second*5)
+	r.drainLate()
+	return true
 }
 
 func (r *Reactor) InvokeRef(fn *func()) bool {
-	if fn == nil {
+	if fn == nil || r.IsClosed() {
+		return false
+	}
+	if !r.queues[PriorityNormal].invokeQ.EnqueueUnsafe(runtimex.FuncToPointer(*fn)) {
 		return false
 	}
-	return r.invokeQ.EnqueueUnsafe(runtimex.FuncToPointer(*fn))
+	r.drainLate()
+	return true
 }
 
 func (r *Reactor) InvokeBlocking(fn func()) bool {
@@ -288,12 +599,32 @@ func (r *Reactor) Spawn(future Future) (*Task, error) {
 		return nil, errors.New("nil future")
 	}
 	task := taskPool.Get()
-	task.init(r.idCounter.Incr(), r, future)
+	task.init(idCounter.Incr(), r, future)
 	if provider, ok := future.(FutureTask); ok {
 		provider.SetTask(task)
 	}
-	if !r.spawnQ.Enqueue(task) {
-		return nil, ErrQueueFull
+	if err := r.enqueueSpawn(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// SpawnPriority spawns future at priority. Wakes of the Task use the same priority.
+func (r *Reactor) SpawnPriority(future Future, priority Priority) (*Task, error) {
+	if future == nil {
+		return nil, errors.New("nil future")
+	}
+	if !priority.valid() {
+		return nil, ErrInvalidPriority
+	}
+	task := taskPool.Get()
+	task.init(idCounter.Incr(), r, future)
+	task.priority = priority
+	if provider, ok := future.(FutureTask); ok {
+		provider.SetTask(task)
+	}
+	if err := r.enqueueSpawn(task); err != nil {
+		return nil, err
 	}
 	return task, nil
 }
@@ -306,54 +637,158 @@ func (r *Reactor) SpawnInterval(future Future, interval time.Duration) (*Task, e
 		interval = 0
 	}
 	task := taskPool.Get()
-	task.init(r.idCounter.Incr(), r, future)
+	task.init(idCounter.Incr(), r, future)
 	task.interval = interval
 	if provider, ok := future.(FutureTask); ok {
 		provider.SetTask(task)
 	}
-	if !r.spawnQ.Enqueue(task) {
-		return nil, ErrQueueFull
+	if err := r.enqueueSpawn(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// SpawnContext spawns future with its lifetime bound to ctx. When ctx is cancelled
+// the Task is stopped and PollClose receives ctx.Err() as the CloseEvent Reason.
+// Context.Std returns a context derived from ctx.
+func (r *Reactor) SpawnContext(ctx context.Context, future Future) (*Task, error) {
+	if ctx == nil {
+		return nil, errors.New("nil context")
+	}
+	if future == nil {
+		return nil, errors.New("nil future")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	task := taskPool.Get()
+	task.init(idCounter.Incr(), r, future)
+	taskCtx, cancel := context.WithCancel(ctx)
+	task.ctx, task.cancel = taskCtx, cancel
+	if provider, ok := future.(FutureTask); ok {
+		provider.SetTask(task)
+	}
+	if err := r.enqueueSpawn(task); err != nil {
+		cancel()
+		return nil, err
+	}
+	if ctx.Done() != nil {
+		go func() {
+			// taskCtx is also cancelled when the Task stops.
+			<-taskCtx.Done()
+			if err := ctx.Err(); err != nil {
+				_ = r.stopContext(task, taskCtx, err)
+			}
+		}()
 	}
 	return task, nil
 }
 
+// stopContext stops task if it is still bound to ctx. Unlike the id the context
+// is preserved across migrations and cleared when the Task is recycled.
+func (r *Reactor) stopContext(task *Task, ctx context.Context, reason error) error {
+	if r.IsClosed() {
+		return os.ErrClosed
+	}
+	if !r.Invoke(func() {
+		if task.ctx != ctx || task.stop {
+			return
+		}
+		if task.reactor != r {
+			// Migrated to another Reactor.
+			if task.reactor != nil {
+				_ = task.reactor.stopContext(task, ctx, reason)
+			}
+			return
+		}
+		r.stopTask(r.now, task, reason)
+	}) {
+		return ErrQueueFull
+	}
+	return nil
+}
+
+func (r *Reactor) enqueueSpawn(task *Task) error {
+	if r.IsClosed() {
+		return os.ErrClosed
+	}
+	if !r.queues[task.priority].spawnQ.Enqueue(task) {
+		return ErrQueueFull
+	}
+	r.drainLate()
+	return nil
+}
+
 func (r *Reactor) SpawnWorkerFn(fn func()) error {
 	return ants.Submit(fn)
 }
 
 func (r *Reactor) run() {
+	defer r.wg.Done()
 	defer func() {
 		e := recover()
 		if e != nil {
 			//logger.Error(util.PanicToError(e))
 		}
 	}()
-	if r.config.LockOSThread {
+	if r.config.LockOSThread || len(r.config.CPU) > 0 {
 		runtime.LockOSThread()
-		defer runtime.UnlockOSThread()
+		if !setAffinity(r.config.CPU, &r.affinityErr) {
+			defer runtime.UnlockOSThread()
+		}
 	}
 
 	r.gid, r.pid = runtimex.GIDPID()
-	tick, err := initTicker(r.tickDur).Register(r.tickDur, r, r.wakeCh)
+	var clock Clock = r.clock
+	if clock == nil {
+		clock = initTicker(r.tickDur)
+	}
+	tick, err := clock.Register(r.tickDur, r, r.wakeCh)
 	if err != nil {
 		panic(err)
 	}
 	defer func() {
 		_ = tick.Close()
 	}()
+	if r.config.SpinMode != SpinPark {
+		r.spin()
+		return
+	}
 	for {
 		select {
 		case v := <-r.wakeCh:
 			r.onWakeMessage(v)
+		case <-r.ctx.Done():
+			r.shutdown()
+			return
 		}
 	}
 }
 
 func (r *Reactor) onWakeMessage(v int64) {
 	r.maybeProcessTick(v)
-	r.now = timex.NanoTime()
+	r.now = r.nanotime()
+	r.runTimers(r.now)
 	for r.flushQueues() > 0 {
-		r.now = timex.NanoTime()
+		r.now = r.nanotime()
+		r.runTimers(r.now)
+	}
+	r.armTimer(r.now)
+	atomic.StoreInt64(&r.processed, r.lastTick)
+}
+
+// nanotime returns the time of the Reactor's Clock.
+func (r *Reactor) nanotime() int64 {
+	if r.clock != nil {
+		return r.clock.Now()
+	}
+	return timex.NanoTime()
+}
+
+// awaitTick waits until tick and all work it produced are processed.
+func (r *Reactor) awaitTick(tick int64) {
+	for atomic.LoadInt64(&r.processed) < tick && atomic.LoadInt64(&r.state) == reactorRunning {
+		runtime.Gosched()
 	}
 }
 
@@ -373,7 +808,12 @@ func (r *Reactor) processTick(tick int64) {
 	interval := int64(r.tickDur)
 	start := timex.NanoTime()
 	begin := start
-	r.tick(tick, begin)
+	if r.clock != nil {
+		r.now = r.clock.Now()
+	} else {
+		r.now = start
+	}
+	r.tick(tick, r.now)
 	end := timex.NanoTime()
 	elapsed := end - begin
 
@@ -386,6 +826,8 @@ func (r *Reactor) processTick(tick int64) {
 	if r.ticksDurMax.Load() < elapsed {
 		r.ticksDurMax.Store(elapsed)
 	}
+	r.tickHist.Observe(elapsed)
+	r.updateLoad(elapsed, interval)
 
 	begin = end
 	r.flushQueues()
@@ -397,7 +839,6 @@ func (r *Reactor) processTick(tick int64) {
 	if elapsed > interval {
 		r.skew.Incr()
 		r.skewDur.Add(elapsed)
-		r.rebalance()
 	}
 }
 
@@ -424,41 +865,129 @@ func (r *Reactor) flushQueues() int {
 		total += count
 		r.wakeLists.Add(int64(count))
 	}
-	if !r.invokeQ.IsEmpty() {
-		count := r.invokeQ.DequeueManyDeref(math.MaxUint32, r.onFn)
-		total += count
-		r.invokes.Add(int64(count))
+	// PriorityHigh preempts each batch of lower priority work. Anything left over
+	// is flushed by the next pass.
+	total += r.flushPriority(PriorityHigh)
+	total += r.flushPriority(PriorityNormal)
+	total += r.flushPriority(PriorityHigh)
+	total += r.flushPriority(PriorityLow)
+	total += r.flushPriority(PriorityHigh)
+	return total
+}
+
+func (r *Reactor) updateLoad(elapsed, interval int64) {
+	load := r.load.Load()
+	load += (elapsed*loadScale/interval - load) / loadSmoothing
+	r.load.Store(load)
+	if r.rebalanceAt <= 0 {
+		return
 	}
-	if !r.wakeQ.IsEmpty() {
-		count := r.wakeQ.DequeueMany(math.MaxUint32, r.onWake)
-		total += count
-		r.wakes.Add(int64(count))
+	if load < r.rebalanceAt {
+		r.overloaded = 0
+		return
 	}
-	if !r.spawnQ.IsEmpty() {
-		count := r.spawnQ.DequeueMany(math.MaxUint32, r.onSpawn)
-		total += count
-		r.spawns.Add(int64(count))
+	r.overloaded++
+	if r.overloaded >= r.config.RebalanceTicks {
+		r.overloaded = 0
+		r.rebalance()
 	}
-	return total
 }
 
-func (r *Reactor) catchup(lastTick, currentTick int64) {
-	//logger.Warn("skew detected of %d ticks", currentTick-1-lastTick)
-	println("skew detected of %d ticks", currentTick-1-lastTick)
-	//logger.Warn("catching up...")
-
-	now := timex.NanoTime()
-	for nextTick := lastTick + 1; nextTick <= currentTick; nextTick++ {
-		r.tick(nextTick, now)
+// leastLoaded finds the running Reactor with the same tick duration and the lowest
+// load that is less than half of this Reactor's load.
+func (r *Reactor) leastLoaded() *Reactor {
+	var (
+		target *Reactor
+		min    = r.load.Load() / 2
+	)
+	for _, other := range reactors.Snapshot() {
+		if other == r || other.tickDur != r.tickDur ||
+			atomic.LoadInt64(&other.state) != reactorRunning {
+			continue
+		}
+		if load := other.load.Load(); load < min {
+			target = other
+			min = load
+		}
 	}
+	return target
 }
 
+// rebalance migrates interval tasks to the least loaded Reactor.
 func (r *Reactor) rebalance() {
+	target := r.leastLoaded()
+	if target == nil {
+		return
+	}
 	//logger.Warn("rebalancing...")
+	max := r.config.RebalanceMax
+	moved := 0
+	adopt := func(task *Task, interval time.Duration, phase int64) bool {
+		return r.migrate(task, target, interval, phase)
+	}
+	moved += r.tickWheel.migrate(max-moved, adopt)
+	if moved < max {
+		moved += r.level2Wheel.migrate(max-moved, adopt)
+	}
+	if moved < max {
+		moved += r.level3Wheel.migrate(max-moved, adopt)
+	}
+	if moved > 0 {
+		r.rebalances.Incr()
+		r.migrations.Add(int64(moved))
+	}
 	//logger.Warn("rebalanced")
 }
 
+// migrate hands an interval Task over to target. The Task keeps its interval phase
+// and TaskSet membership. Tasks with a pending WakeAfter are left in place.
+func (r *Reactor) migrate(task *Task, target *Reactor, interval time.Duration, phase int64) bool {
+	if task.reactor != r || task.wakeAfter > 0 {
+		return false
+	}
+	// Task IDs are unique across Reactors and stay the same so Promises, Supervisors
+	// and Schedules holding the ID still match the Task.
+	slots := task.taskSlots()
+	r.tasks.Delete(task.id)
+	task.reactor = target
+	task.rehome(slots)
+	if !target.Invoke(func() {
+		target.adopt(task, interval, phase)
+	}) {
+		task.reactor = r
+		task.rehome(task.taskSlots())
+		r.tasks.Put(task.id, task)
+		return false
+	}
+	return true
+}
+
+// adopt schedules a Task migrated from another Reactor.
+func (r *Reactor) adopt(task *Task, interval time.Duration, phase int64) {
+	if task.stop || task.reactor != r {
+		return
+	}
+	r.tasks.Put(task.id, task)
+	if r.funcs != nil {
+		task.stats = r.funcs.Future(task.future)
+	} else {
+		task.stats = nil
+	}
+	if task.interval != interval {
+		if task.interval > 0 {
+			r.schedule(task, task.interval, false)
+		}
+		return
+	}
+	if !r.tickWheel.scheduleAt(task, interval, phase) &&
+		!r.level2Wheel.scheduleAt(task, interval, phase) &&
+		!r.level3Wheel.scheduleAt(task, interval, phase) {
+		r.schedule(task, interval, false)
+	}
+}
+
 func (r *Reactor) tick(tick int64, now int64) {
+	r.refillLimiters(now)
 	r.tickWheel.tick(now, r.onTick)
 	if tick%r.ticksPerLevel2 == 0 {
 		//logger.Debug("level 2 wheel Tick", tick)
@@ -471,11 +1000,18 @@ func (r *Reactor) tick(tick int64, now int64) {
 }
 
 func (r *Reactor) onTick(now int64, list *taskSwapList, slot *taskSwapSlot, task *Task) bool {
+	if slot.fn != nil {
+		slot.fn()
+		return false
+	}
 	if slot.wake {
 		r.pollWake(now, task)
 		return false
 	}
-	return r.pollInterval(now, list, task)
+	if r.catchingUp && r.skipInterval(now, task) {
+		return true
+	}
+	return r.pollInterval(now, list.dur, task)
 }
 
 func (r *Reactor) schedule(task *Task, delay time.Duration, wake bool) {
@@ -485,17 +1021,19 @@ func (r *Reactor) schedule(task *Task, delay time.Duration, wake bool) {
 		r.level2Wheel.schedule(task, delay, wake)
 	} else if delay < r.level3Wheel.maxDur && r.level3Wheel.tickDur > 0 {
 		r.level3Wheel.schedule(task, delay, wake)
+	} else {
+		r.scheduleOverflow(task, delay, wake)
 	}
 }
 
-func (r *Reactor) stopTask(time int64, task *Task) {
+func (r *Reactor) stopTask(time int64, task *Task, reason any) {
 	defer func() {
-		task.remove()
 		if e := recover(); e != nil {
-			err := util.PanicToError(e)
-			_ = err
-			//logger.Error(err, "Reactor.invoke panic")
+			r.onPanic(time, task, ReasonClose, e)
 		}
+		task.closeAll()
+		task.cancelContext()
+		task.remove()
 	}()
 	_, ok := r.tasks.Delete(task.id)
 	if !ok {
@@ -508,7 +1046,7 @@ func (r *Reactor) stopTask(time int64, task *Task) {
 		err := pc.PollClose(CloseEvent{
 			Task:   task,
 			Time:   time,
-			Reason: nil,
+			Reason: reason,
 		})
 		if err != nil {
 			//logger.Warn(err)
@@ -519,26 +1057,40 @@ func (r *Reactor) stopTask(time int64, task *Task) {
 func (r *Reactor) invoke(fn func()) {
 	defer func() {
 		if e := recover(); e != nil {
-			err := util.PanicToError(e)
-			_ = err
-			//logger.Error(err, "Reactor.invoke panic")
+			r.onPanic(r.now, nil, ReasonInvoke, e)
 		}
 	}()
-	if fn != nil {
+	if fn == nil {
+		return
+	}
+	if r.funcs == nil {
 		fn()
+		return
 	}
+	stats := r.funcs.Func(fn)
+	begin := timex.NanoTime()
+	defer func() {
+		stats.record(ReasonStart, timex.NanoTime()-begin)
+		if e := recover(); e != nil {
+			stats.panic(util.PanicToError(e))
+			panic(e)
+		}
+	}()
+	fn()
 }
 
 func (r *Reactor) pollStart(now int64, task *Task) {
 	defer func() {
 		if e := recover(); e != nil {
-			err := util.PanicToError(e)
-			_ = err
-			//logger.Error(err, "Reactor.pollInvoke panic")
+			r.onPanic(now, task, ReasonStart, e)
 		}
 	}()
 	task.started = now
-	err := task.future.Poll(Context{
+	if r.funcs != nil {
+		task.stats = r.funcs.Future(task.future)
+	}
+	r.tasks.Put(task.id, task)
+	err := r.poll(task, Context{
 		Task:   task,
 		Time:   now,
 		Reason: ReasonStart,
@@ -547,25 +1099,30 @@ func (r *Reactor) pollStart(now int64, task *Task) {
 	if err != nil {
 		if err == ErrStop {
 			task.stop = true
-		} else {
-			//logger.Warn(err)
+		} else if action := r.onError(now, task, ReasonStart, err); action != FaultIgnore {
+			return
 		}
 	}
 
 	if task.stop {
-		r.stopTask(now, task)
+		r.stopTask(now, task, nil)
 		return
 	}
 
-	r.tasks.Put(task.id, task)
-
-	if task.wakeAfter > 0 {
-		r.schedule(task, task.wakeAfter, true)
+	if wakeAfter := task.wakeAfter; wakeAfter > 0 {
+		task.wakeAfter = 0
+		r.schedule(task, wakeAfter, true)
 	}
 
-	if task.interval > 0 {
+	// A restarted Task may still be in the wheel.
+	if task.interval > 0 && !task.scheduled {
+		task.scheduled = true
 		r.schedule(task, task.interval, false)
 	}
+
+	if task.cron != nil {
+		r.nextSchedule(now, task, time.Time{})
+	}
 }
 
 func (r *Reactor) pollWakeList(now int64, list *WakeList) {
@@ -598,11 +1155,17 @@ func (r *Reactor) pollWake(now int64, task *Task) {
 	if task.stop {
 		return
 	}
+	if task.reactor != r {
+		// Migrated to another Reactor.
+		_ = task.Wake()
+		return
+	}
+	if r.maybeRestart(now, task) {
+		return
+	}
 	defer func() {
 		if e := recover(); e != nil {
-			err := util.PanicToError(e)
-			_ = err
-			//logger.Error(err, "Reactor.pollInvoke panic")
+			r.onPanic(now, task, ReasonWake, e)
 		}
 	}()
 
@@ -614,8 +1177,12 @@ func (r *Reactor) pollWake(now int64, task *Task) {
 		return
 	}
 
+	if r.deferOverBudget(task) {
+		return
+	}
+
 	task.wakes++
-	err := task.future.Poll(Context{
+	err := r.poll(task, Context{
 		Task:   task,
 		Time:   now,
 		Reason: ReasonWake,
@@ -624,19 +1191,20 @@ func (r *Reactor) pollWake(now int64, task *Task) {
 	if err != nil {
 		if err == ErrStop {
 			task.stop = true
-		} else {
-			//logger.Warn(err)
+		} else if action := r.onError(now, task, ReasonWake, err); action != FaultIgnore {
+			return
 		}
 	}
 
 	if task.stop {
-		r.stopTask(now, task)
+		r.stopTask(now, task, nil)
 		return
 	}
 
 	newWakeAfter := task.wakeAfter
 	if newWakeAfter != wakeAfter {
 		if newWakeAfter > 0 {
+			task.wakeAfter = 0
 			r.schedule(task, newWakeAfter, true)
 		}
 	}
@@ -650,41 +1218,52 @@ func (r *Reactor) pollWake(now int64, task *Task) {
 	}
 }
 
-func (r *Reactor) pollInterval(now int64, list *taskSwapList, task *Task) bool {
-	if task.stop || task.interval == 0 || list.dur != task.interval {
+func (r *Reactor) pollInterval(now int64, dur time.Duration, task *Task) (keep bool) {
+	if task.stop || task.interval == 0 || dur != task.interval || task.reactor != r {
 		// remove
 		return false
 	}
+	if task.restartAt > 0 {
+		// Keep the interval while restarting.
+		return true
+	}
 
 	defer func() {
 		if e := recover(); e != nil {
-			err := util.PanicToError(e)
-			_ = err
-			//logger.Error(err, "Reactor.pollInterval panic")
+			keep = r.onPanic(now, task, ReasonInterval, e) == FaultRestart
 		}
 	}()
 
 	interval := task.interval
 	wakeAfter := task.wakeAfter
+	reason := ReasonInterval
+	if r.catchingUp {
+		reason = ReasonIntervalBehind
+	}
 
 	task.intervals++
-	err := task.future.Poll(Context{
+	err := r.poll(task, Context{
 		Task:     task,
 		Time:     now,
 		Interval: interval,
-		Reason:   ReasonInterval,
+		Reason:   reason,
 	})
 
 	if err != nil {
 		if err == ErrStop {
 			task.stop = true
 		} else {
-			//logger.Warn(err)
+			switch r.onError(now, task, ReasonInterval, err) {
+			case FaultStop:
+				return false
+			case FaultRestart:
+				return true
+			}
 		}
 	}
 
 	if task.stop {
-		r.stopTask(now, task)
+		r.stopTask(now, task, nil)
 		// remove
 		return false
 	}
@@ -692,6 +1271,7 @@ func (r *Reactor) pollInterval(now int64, list *taskSwapList, task *Task) bool {
 	newWakeAfter := task.wakeAfter
 	if newWakeAfter != wakeAfter {
 		if newWakeAfter > 0 {
+			task.wakeAfter = 0
 			r.schedule(task, newWakeAfter, true)
 		}
 	}
@@ -729,6 +1309,9 @@ func (r *Reactor) Print() {
 	//fmt.Println("Jobs Avg Dur 	", Time.Duration(r.invokesDur.Load())/Time.Duration(r.invokes.Load()))
 	//fmt.Println("Interval 		", r.tickDur)
 	fmt.Println("Tick CPU 		", float64(avg)/float64(r.tickDur))
+	fmt.Println("Load 	 		", r.Load())
+	fmt.Println("Rebalances 		", r.rebalances.Load())
+	fmt.Println("Migrations 		", r.migrations.Load())
 	fmt.Println("Min 	 		", time.Duration(r.ticksDurMin.Load()))
 	fmt.Println("Max 	 		", time.Duration(r.ticksDurMax.Load()))
 	//for i, slots := range r.tickWheel {
@@ -1,9 +1,11 @@
 package reactor
 
 import (
+	"context"
 	"fmt"
 	"github.com/moontrade/kirana/pkg/counter"
 	"github.com/moontrade/kirana/pkg/timex"
+	"os"
 	"runtime"
 	"runtime/debug"
 	"sync"
@@ -129,7 +131,7 @@ func TestLoopInvokeConcurrent(t *testing.T) {
 	elapsed := start.ElapsedDur()
 
 	t.Log("final count", c.Load(), "overflow count", overflowCount.Load(),
-		"wakes", w.invokeQ.WakeCount(), "wake miss", w.invokeQ.WakeChanFullCount(),
+		"wakes", w.queues[PriorityNormal].invokeQ.WakeCount(), "wake miss", w.queues[PriorityNormal].invokeQ.WakeChanFullCount(),
 		"duration", elapsed.String(), "per op", (elapsed / time.Duration(c.Load())).String())
 }
 
@@ -218,3 +220,224 @@ func printMemStat(ms *runtime.MemStats) {
 	//}
 	//runtime.GC()
 }
+
+func TestReactorClose(t *testing.T) {
+	w, err := NewReactor(Config{Level1Wheel: NewWheel(Millis25)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Start()
+
+	c := new(counter.Counter)
+	task := &CloseRecorder{c: c, closed: make(chan struct{})}
+	if _, err = w.SpawnInterval(task, time.Millisecond*100); err != nil {
+		t.Fatal(err)
+	}
+	for c.Load() == 0 {
+		runtime.Gosched()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err = w.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	<-task.closed
+	if task.reason != ErrShutdown {
+		t.Fatal("expected ErrShutdown reason, got", task.reason)
+	}
+	if _, err = w.Spawn(&SimpleTask{c: c}); err != os.ErrClosed {
+		t.Fatal("expected os.ErrClosed, got", err)
+	}
+	if w.Invoke(func() {}) {
+		t.Fatal("invoke accepted after close")
+	}
+	for _, r := range reactors.Snapshot() {
+		if r == w {
+			t.Fatal("reactor still registered")
+		}
+	}
+}
+
+func TestReactorCloseLateSpawn(t *testing.T) {
+	w, err := NewReactor(Config{Level1Wheel: NewWheel(Millis25)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Start()
+	if err = w.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A Spawn that passed the IsClosed check before Close enqueues after the drain.
+	c := new(counter.Counter)
+	task := &CloseRecorder{c: c, closed: make(chan struct{})}
+	late := taskPool.Get()
+	late.init(idCounter.Incr(), w, task)
+	if !w.queues[late.priority].spawnQ.Enqueue(late) {
+		t.Fatal("enqueue failed")
+	}
+	w.drainLate()
+	select {
+	case <-task.closed:
+	default:
+		t.Fatal("late spawn was dropped")
+	}
+	if task.reason != ErrShutdown {
+		t.Fatal("expected ErrShutdown reason, got", task.reason)
+	}
+}
+
+func TestReactorIDNotReused(t *testing.T) {
+	live, err := NewReactor(Config{Level1Wheel: NewWheel(Millis25)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close(context.Background())
+	closed, err := NewReactor(Config{Level1Wheel: NewWheel(Millis25)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = closed.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReactor(Config{Level1Wheel: NewWheel(Millis25)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close(context.Background())
+	if r.ID() == live.ID() || r.ID() == closed.ID() {
+		t.Fatalf("expected a new ID, got %d with live %d and closed %d", r.ID(), live.ID(), closed.ID())
+	}
+
+	var set TaskSet
+	c := new(counter.Counter)
+	a := &taskSetTask{SimpleTask: SimpleTask{c: c}}
+	b := &taskSetTask{SimpleTask: SimpleTask{c: c}}
+	if _, err = set.SpawnOn(live, a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = set.SpawnOn(r, b); err != nil {
+		t.Fatal(err)
+	}
+	if set.NumReactors() != 2 {
+		t.Fatal("expected a WakeList per Reactor, got", set.NumReactors())
+	}
+}
+
+type taskSetTask struct {
+	SimpleTask
+	TaskProvider
+}
+
+type stdRecorder struct {
+	CloseRecorder
+	std chan context.Context
+}
+
+func (t *stdRecorder) Poll(ctx Context) error {
+	if ctx.Reason == ReasonStart {
+		t.std <- ctx.Std()
+	}
+	return t.CloseRecorder.Poll(ctx)
+}
+
+func TestReactorSpawnContext(t *testing.T) {
+	w, err := NewReactor(Config{Level1Wheel: NewWheel(Millis25)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Start()
+	defer w.Close(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	task := &stdRecorder{
+		CloseRecorder: CloseRecorder{c: new(counter.Counter), closed: make(chan struct{})},
+		std:           make(chan context.Context, 1),
+	}
+	if _, err = w.SpawnContext(ctx, task); err != nil {
+		t.Fatal(err)
+	}
+	std := <-task.std
+	if std.Err() != nil {
+		t.Fatal("std context done before cancel")
+	}
+	cancel()
+	select {
+	case <-task.closed:
+	case <-time.After(time.Second * 5):
+		t.Fatal("task not stopped after cancel")
+	}
+	if task.reason != context.Canceled {
+		t.Fatal("expected context.Canceled reason, got", task.reason)
+	}
+	<-std.Done()
+
+	if _, err = w.SpawnContext(ctx, &SimpleTask{c: new(counter.Counter)}); err != context.Canceled {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+
+	// Std is cancelled when a Task spawned without a context stops.
+	task = &stdRecorder{
+		CloseRecorder: CloseRecorder{c: new(counter.Counter), closed: make(chan struct{})},
+		std:           make(chan context.Context, 1),
+	}
+	spawned, err := w.Spawn(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	std = <-task.std
+	if err = w.Stop(spawned, ErrStop); err != nil {
+		t.Fatal(err)
+	}
+	<-std.Done()
+}
+
+func TestReactorRebalance(t *testing.T) {
+	src, err := NewReactor(Config{Level1Wheel: NewWheel(Millis25)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := NewReactor(Config{Level1Wheel: NewWheel(Millis25)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.Start()
+	dst.Start()
+	defer func() {
+		_ = src.Close(context.Background())
+		_ = dst.Close(context.Background())
+	}()
+
+	c := new(counter.Counter)
+	task, err := src.SpawnInterval(&SimpleTask{c: c}, time.Millisecond*100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for c.Load() == 0 {
+		runtime.Gosched()
+	}
+
+	id := task.ID()
+	done := make(chan struct{})
+	src.Invoke(func() {
+		defer close(done)
+		// Simulate an overloaded Reactor.
+		src.load.Store(loadScale)
+		src.rebalance()
+	})
+	<-done
+	if src.migrations.Load() != 1 {
+		t.Fatal("expected 1 migration, got", src.migrations.Load())
+	}
+	if task.Reactor() == src {
+		t.Fatal("task was not migrated")
+	}
+	if task.ID() != id {
+		t.Fatalf("expected the ID %d to be kept, got %d", id, task.ID())
+	}
+	polls := c.Load()
+	for c.Load() == polls {
+		runtime.Gosched()
+	}
+}
@@ -0,0 +1,188 @@
+package reactor
+
+import (
+	"errors"
+	"time"
+)
+
+// taskSchedule is the state of a Task spawned with SpawnSchedule.
+type taskSchedule struct {
+	schedule Schedule
+	// next is the wall-clock time of the next poll.
+	next time.Time
+	// deadline is next in Reactor time.
+	deadline int64
+	// seq invalidates the wheel slots and timers of previous arms.
+	seq    int64
+	behind bool
+}
+
+// SpawnSchedule spawns future to be polled with ReasonInterval at each wall-clock
+// time of schedule such as a Cron or Aligned. Context.Scheduled is the intended time
+// of the poll. Polls delivered a tick or more late use ReasonIntervalBehind and
+// missed times are handled according to Config.CatchUp.
+//
+// Distant times are tracked on the Level2 and Level3 wheels and fired by a Timer
+// once they are within reach of the Level1 wheel.
+func (r *Reactor) SpawnSchedule(future Future, schedule Schedule) (*Task, error) {
+	if future == nil {
+		return nil, errors.New("nil future")
+	}
+	if schedule == nil {
+		return nil, errors.New("nil schedule")
+	}
+	task := taskPool.Get()
+	task.init(idCounter.Incr(), r, future)
+	task.cron = &taskSchedule{schedule: schedule}
+	if provider, ok := future.(FutureTask); ok {
+		provider.SetTask(task)
+	}
+	if err := r.enqueueSpawn(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// WallTime returns the wall-clock time of Reactor time now. With a Clock it is
+// offset from Config.Epoch.
+func (r *Reactor) WallTime(now int64) time.Time {
+	if r.clock == nil {
+		return time.Now().Add(time.Duration(now - r.nanotime()))
+	}
+	return r.epoch.Add(time.Duration(now - r.epochNano))
+}
+
+// nextSchedule arms the first time of the Task's Schedule after last. A zero last
+// starts from the current time.
+func (r *Reactor) nextSchedule(now int64, task *Task, last time.Time) {
+	cs := task.cron
+	wall := r.WallTime(now)
+	var next time.Time
+	if last.IsZero() {
+		next = cs.schedule.Next(wall)
+	} else {
+		next = cs.schedule.Next(last)
+		if !next.IsZero() && !next.After(wall) {
+			switch r.config.CatchUp {
+			case CatchUpSkip:
+				next = cs.schedule.Next(wall)
+			case CatchUpCoalesce:
+				if cs.behind {
+					next = cs.schedule.Next(wall)
+				}
+			}
+		}
+	}
+	cs.seq++
+	if next.IsZero() {
+		// The Schedule has ended.
+		cs.next = next
+		return
+	}
+	cs.next = next
+	cs.deadline = now + int64(next.Sub(wall))
+	r.armSchedule(task, cs.seq)
+}
+
+// armSchedule moves the Task closer to its deadline on the coarsest wheel that
+// wakes before it or on the timer heap once no wheel does.
+func (r *Reactor) armSchedule(task *Task, seq int64) {
+	var (
+		cs   = task.cron
+		id   = task.id
+		fire func(now int64)
+	)
+	fire = func(now int64) {
+		if task.id != id || task.cron != cs || cs.seq != seq || task.stop {
+			return
+		}
+		if task.reactor != r {
+			// Migrated to another Reactor.
+			to := task.reactor
+			to.Invoke(func() {
+				if task.id == id && task.cron == cs && cs.seq == seq {
+					to.armSchedule(task, seq)
+				}
+			})
+			return
+		}
+		if cs.deadline > now {
+			r.armSchedule(task, seq)
+			return
+		}
+		if r.pollSchedule(now, task) {
+			r.nextSchedule(now, task, cs.next)
+		}
+	}
+
+	var (
+		remaining = time.Duration(cs.deadline - r.now)
+		wheel     *Wheel
+		hop       time.Duration
+	)
+	for _, w := range [...]*Wheel{&r.tickWheel, &r.level2Wheel, &r.level3Wheel} {
+		if d := w.floor(remaining); d > hop {
+			wheel, hop = w, d
+		}
+	}
+	timer := &Timer{reactor: r, task: task, at: cs.deadline, index: -1, fire: fire}
+	if wheel == nil {
+		r.addTimer(timer)
+		return
+	}
+	// The slot hands over to the timer heap as wheels are iterated in place.
+	wheel.scheduleFunc(task, hop, func() {
+		timer.at = r.now
+		r.addTimer(timer)
+	})
+}
+
+// pollSchedule polls a Task at the time of its Schedule. Returns false if the
+// Schedule should not be armed again.
+func (r *Reactor) pollSchedule(now int64, task *Task) (keep bool) {
+	if task.restartAt > 0 {
+		// Keep the Schedule while restarting.
+		return true
+	}
+
+	defer func() {
+		if e := recover(); e != nil {
+			keep = r.onPanic(now, task, ReasonInterval, e) == FaultIgnore
+		}
+	}()
+
+	cs := task.cron
+	wakeAfter := task.wakeAfter
+	reason := ReasonInterval
+	cs.behind = r.catchingUp || now-cs.deadline >= int64(r.tickDur)
+	if cs.behind {
+		reason = ReasonIntervalBehind
+	}
+
+	task.intervals++
+	err := r.poll(task, Context{
+		Task:      task,
+		Time:      now,
+		Reason:    reason,
+		Scheduled: cs.next,
+	})
+
+	if err != nil {
+		if err == ErrStop {
+			task.stop = true
+		} else if r.onError(now, task, ReasonInterval, err) != FaultIgnore {
+			return false
+		}
+	}
+
+	if task.stop {
+		r.stopTask(now, task, nil)
+		return false
+	}
+
+	if newWakeAfter := task.wakeAfter; newWakeAfter != wakeAfter && newWakeAfter > 0 {
+		task.wakeAfter = 0
+		r.schedule(task, newWakeAfter, true)
+	}
+	return true
+}
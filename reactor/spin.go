@@ -0,0 +1,105 @@
+package reactor
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/moontrade/kirana/pkg/timex"
+)
+
+// SpinMode is how the Reactor goroutine waits for work.
+type SpinMode uint8
+
+const (
+	SpinPark   SpinMode = 0 // SpinPark blocks on the wake channel
+	SpinHybrid SpinMode = 1 // SpinHybrid busy-polls the queues for SpinBudget after the last work before parking
+	SpinBusy   SpinMode = 2 // SpinBusy busy-polls the queues and never parks. Pair with LockOSThread
+)
+
+const (
+	DefaultSpinBudget = time.Microsecond * 50
+	// spinYield is the number of idle polls between yielding to the Go scheduler.
+	spinYield = 64
+)
+
+// hasWork returns true if any of the queues are not empty.
+func (r *Reactor) hasWork() bool {
+	if !r.wakeListQ.IsEmpty() {
+		return true
+	}
+	for p := range r.queues {
+		if !r.queues[p].isEmpty() {
+			return true
+		}
+	}
+	return false
+}
+
+// spin is the event loop of SpinHybrid and SpinBusy. Queues are polled directly so
+// wakes don't pay for the channel handoff. Ticks still arrive on the wake channel.
+func (r *Reactor) spin() {
+	var (
+		idle      = 0
+		spinStart = timex.NanoTime()
+		budget    = int64(r.config.SpinBudget)
+	)
+	for {
+		select {
+		case v := <-r.wakeCh:
+			r.spinDone(spinStart)
+			r.onWakeMessage(v)
+			idle = 0
+			spinStart = timex.NanoTime()
+			continue
+		case <-r.ctx.Done():
+			r.spinDone(spinStart)
+			r.shutdown()
+			return
+		default:
+		}
+
+		if r.hasWork() || (len(r.timers) > 0 && r.timers[0].at <= r.nanotime()) {
+			r.spinDone(spinStart)
+			r.onWakeMessage(0)
+			idle = 0
+			spinStart = timex.NanoTime()
+			continue
+		}
+
+		idle++
+		if idle%spinYield != 0 {
+			continue
+		}
+		if r.config.SpinMode == SpinHybrid && timex.NanoTime()-spinStart >= budget {
+			r.spinDone(spinStart)
+			if !r.park() {
+				return
+			}
+			idle = 0
+			spinStart = timex.NanoTime()
+			continue
+		}
+		runtime.Gosched()
+	}
+}
+
+func (r *Reactor) spinDone(spinStart int64) {
+	r.spins.Incr()
+	r.spinsDur.Add(timex.NanoTime() - spinStart)
+}
+
+// park blocks on the wake channel. Returns false once the Reactor is shut down.
+func (r *Reactor) park() bool {
+	begin := timex.NanoTime()
+	r.parks.Incr()
+	select {
+	case v := <-r.wakeCh:
+		r.parksDur.Add(timex.NanoTime() - begin)
+		r.onWakeMessage(v)
+		return true
+	case <-r.ctx.Done():
+		r.parksDur.Add(timex.NanoTime() - begin)
+		r.shutdown()
+		return false
+	}
+}
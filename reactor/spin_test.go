@@ -0,0 +1,60 @@
+package reactor
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/moontrade/kirana/pkg/counter"
+)
+
+func TestReactorSpinMode(t *testing.T) {
+	for _, mode := range []SpinMode{SpinBusy, SpinHybrid} {
+		r, err := NewReactor(Config{
+			Level1Wheel: NewWheel(Millis25),
+			SpinMode:    mode,
+			SpinBudget:  time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Start()
+
+		c := new(counter.Counter)
+		task, err := r.Spawn(&SimpleTask{c: c})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := int64(1); i <= 100; i++ {
+			for c.Load() < i {
+				runtime.Gosched()
+			}
+			if err = task.Wake(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for c.Load() < 101 {
+			runtime.Gosched()
+		}
+		time.Sleep(time.Millisecond * 50)
+
+		stats := r.SnapshotStats()
+		if stats.spins.Load() == 0 {
+			t.Fatal("expected spins")
+		}
+		switch mode {
+		case SpinBusy:
+			if stats.parks.Load() != 0 {
+				t.Fatal("busy mode parked")
+			}
+		case SpinHybrid:
+			if stats.parks.Load() == 0 || stats.parksDur.Load() == 0 {
+				t.Fatal("hybrid mode never parked")
+			}
+		}
+		if err = r.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package reactor
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/moontrade/kirana/pkg/spinlock"
+	"github.com/moontrade/kirana/pkg/util"
+)
+
+var (
+	// ErrMaxRestarts is returned by a Supervisor that exceeded its restart intensity.
+	ErrMaxRestarts = errors.New("supervisor max restarts exceeded")
+	// ErrRestart is the CloseEvent Reason of a child stopped by its Supervisor to be restarted.
+	ErrRestart = errors.New("supervisor restart")
+)
+
+// RestartPolicy decides whether a child is restarted when it exits.
+type RestartPolicy uint8
+
+const (
+	Permanent RestartPolicy = 0 // Permanent is always restarted
+	Transient RestartPolicy = 1 // Transient is restarted only if it exits with an error or panic
+	Temporary RestartPolicy = 2 // Temporary is never restarted
+)
+
+// Strategy decides which children are restarted when a child exits.
+type Strategy uint8
+
+const (
+	OneForOne  Strategy = 0 // OneForOne restarts only the exited child
+	OneForAll  Strategy = 1 // OneForAll stops and restarts all children
+	RestForOne Strategy = 2 // RestForOne stops and restarts the exited child and every child started after it
+)
+
+const (
+	DefaultSupervisorMaxRestarts = 3
+	DefaultSupervisorWindow      = time.Second * 5
+	DefaultSupervisorBackoffMin  = time.Millisecond * 100
+	DefaultSupervisorBackoffMax  = time.Second * 10
+)
+
+// ChildSpec describes a child of a Supervisor.
+type ChildSpec struct {
+	Name string
+	// Start creates a new Future each time the child is started.
+	Start func() Future
+	// Interval is the Task interval or 0 for none.
+	Interval time.Duration
+	Restart  RestartPolicy
+}
+
+type SupervisorConfig struct {
+	Strategy Strategy
+	// MaxRestarts within Window before the Supervisor gives up, stops all children
+	// and exits with ErrMaxRestarts.
+	MaxRestarts int
+	Window      time.Duration
+	// BackoffMin is the delay before a restart which doubles for each restart
+	// within Window up to BackoffMax.
+	BackoffMin time.Duration
+	BackoffMax time.Duration
+}
+
+// Supervisor is a Future that owns child Futures and restarts them when they exit
+// with an error or panic. Children are spawned on the Supervisor's Reactor. A
+// Supervisor may be the child of another Supervisor in which case exceeding the
+// restart intensity escalates to the parent.
+type Supervisor struct {
+	TaskProvider
+	config    SupervisorConfig
+	children  []*supervisedChild
+	exits     []childExit
+	history   []int64
+	restartAt int64
+	restarts  int
+	err       error
+	closed    bool
+	mu        spinlock.Mutex
+}
+
+type supervisedChild struct {
+	spec    ChildSpec
+	task    *Task
+	taskID  int64
+	gen     int
+	running bool
+	restart bool
+}
+
+type childExit struct {
+	child *supervisedChild
+	gen   int
+	err   error
+}
+
+func NewSupervisor(config SupervisorConfig, children ...ChildSpec) *Supervisor {
+	if config.MaxRestarts <= 0 {
+		config.MaxRestarts = DefaultSupervisorMaxRestarts
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultSupervisorWindow
+	}
+	if config.BackoffMin <= 0 {
+		config.BackoffMin = DefaultSupervisorBackoffMin
+	}
+	if config.BackoffMax < config.BackoffMin {
+		config.BackoffMax = DefaultSupervisorBackoffMax
+		if config.BackoffMax < config.BackoffMin {
+			config.BackoffMax = config.BackoffMin
+		}
+	}
+	s := &Supervisor{config: config}
+	for _, spec := range children {
+		s.children = append(s.children, &supervisedChild{spec: spec})
+	}
+	return s
+}
+
+// Restarts returns the total number of child restarts.
+func (s *Supervisor) Restarts() int { return s.restarts }
+
+// Err returns the error the Supervisor exited with.
+func (s *Supervisor) Err() error { return s.err }
+
+func (s *Supervisor) Poll(ctx Context) error {
+	if ctx.Reason == ReasonStart {
+		for _, child := range s.children {
+			s.startChild(ctx, child)
+		}
+	}
+
+	s.mu.Lock()
+	exits := s.exits
+	s.exits = nil
+	s.mu.Unlock()
+
+	for _, exit := range exits {
+		if err := s.onExit(ctx, exit); err != nil {
+			// Escalate
+			s.err = err
+			s.stopChildren(0, err)
+			ctx.Stop()
+			return err
+		}
+	}
+
+	if s.restartAt == 0 {
+		return nil
+	}
+	if remaining := s.restartAt - ctx.Time; remaining > 0 {
+		ctx.WakeAfter(time.Duration(remaining))
+		return nil
+	}
+	s.restartAt = 0
+	for _, child := range s.children {
+		if child.restart {
+			child.restart = false
+			s.startChild(ctx, child)
+		}
+	}
+	return nil
+}
+
+func (s *Supervisor) PollClose(event CloseEvent) error {
+	s.mu.Lock()
+	s.closed = true
+	s.exits = nil
+	s.mu.Unlock()
+	reason := event.Reason
+	if reason == nil {
+		reason = ErrStop
+	}
+	s.stopChildren(0, reason)
+	return nil
+}
+
+func (s *Supervisor) onExit(ctx Context, exit childExit) error {
+	child := exit.child
+	if exit.gen != child.gen || !child.running {
+		// Stopped by the Supervisor.
+		return nil
+	}
+	child.running = false
+	child.task = nil
+	if exit.err == ErrShutdown {
+		return nil
+	}
+	switch child.spec.Restart {
+	case Temporary:
+		return nil
+	case Transient:
+		if exit.err == nil {
+			return nil
+		}
+	}
+
+	// Restart intensity
+	now := ctx.Time
+	window := int64(s.config.Window)
+	history := s.history[:0]
+	for _, at := range s.history {
+		if now-at < window {
+			history = append(history, at)
+		}
+	}
+	s.history = history
+	if len(s.history) >= s.config.MaxRestarts {
+		return fmt.Errorf("%w: %d restarts within %s: %s: %v",
+			ErrMaxRestarts, len(s.history), s.config.Window, child.spec.Name, exit.err)
+	}
+	s.history = append(s.history, now)
+	s.restarts++
+
+	index := 0
+	for i, c := range s.children {
+		if c == child {
+			index = i
+			break
+		}
+	}
+	switch s.config.Strategy {
+	case OneForAll:
+		s.restartFrom(0, child)
+	case RestForOne:
+		s.restartFrom(index, child)
+	default:
+		child.restart = true
+	}
+
+	backoff := s.config.BackoffMin
+	for i := 1; i < len(s.history) && backoff < s.config.BackoffMax; i++ {
+		backoff <<= 1
+	}
+	if backoff > s.config.BackoffMax {
+		backoff = s.config.BackoffMax
+	}
+	if at := now + int64(backoff); at > s.restartAt {
+		s.restartAt = at
+	}
+	return nil
+}
+
+// restartFrom marks the exited child and running non-Temporary children from index
+// from to be restarted and stops the running ones.
+func (s *Supervisor) restartFrom(from int, exited *supervisedChild) {
+	for _, child := range s.children[from:] {
+		if child == exited || (child.running && child.spec.Restart != Temporary) {
+			child.restart = true
+		}
+	}
+	s.stopChildren(from, ErrRestart)
+}
+
+func (s *Supervisor) startChild(ctx Context, child *supervisedChild) {
+	if child.spec.Start == nil {
+		return
+	}
+	child.gen++
+	future := &supervisedFuture{
+		supervisor: s,
+		child:      child,
+		gen:        child.gen,
+		future:     child.spec.Start(),
+	}
+	var (
+		task *Task
+		err  error
+	)
+	if child.spec.Interval > 0 {
+		task, err = ctx.Reactor().SpawnInterval(future, child.spec.Interval)
+	} else {
+		task, err = ctx.Reactor().Spawn(future)
+	}
+	child.running = true
+	if err != nil {
+		s.exited(child, child.gen, err)
+		return
+	}
+	child.task = task
+	child.taskID = task.id
+}
+
+// stopChildren stops all running children from index from.
+func (s *Supervisor) stopChildren(from int, reason any) {
+	for i := len(s.children) - 1; i >= from; i-- {
+		child := s.children[i]
+		if !child.running {
+			continue
+		}
+		task := child.task
+		id := child.taskID
+		child.gen++
+		child.running = false
+		child.task = nil
+		if task != nil && task.id == id {
+			_ = task.reactor.Stop(task, reason)
+		}
+	}
+}
+
+// exited is called from the child's PollClose on any Reactor goroutine.
+func (s *Supervisor) exited(child *supervisedChild, gen int, err error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.exits = append(s.exits, childExit{child: child, gen: gen, err: err})
+	s.mu.Unlock()
+	_ = s.Wake()
+}
+
+// supervisedFuture wraps the Future of a child to intercept its errors, panics and exit.
+type supervisedFuture struct {
+	TaskProvider
+	supervisor *Supervisor
+	child      *supervisedChild
+	gen        int
+	future     Future
+	err        error
+}
+
+func (f *supervisedFuture) SetTask(task *Task) {
+	f.task = task
+	if provider, ok := f.future.(FutureTask); ok {
+		provider.SetTask(task)
+	}
+}
+
+func (f *supervisedFuture) Poll(ctx Context) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			f.err = util.PanicToError(e)
+			ctx.Stop()
+			err = nil
+		}
+	}()
+	err = f.future.Poll(ctx)
+	if err != nil && err != ErrStop {
+		f.err = err
+		ctx.Stop()
+		return nil
+	}
+	return err
+}
+
+func (f *supervisedFuture) PollClose(event CloseEvent) error {
+	defer func() {
+		if e := recover(); e != nil && f.err == nil {
+			f.err = util.PanicToError(e)
+		}
+		f.supervisor.exited(f.child, f.gen, f.err)
+	}()
+	if f.err == nil {
+		if err, ok := event.Reason.(error); ok {
+			f.err = err
+		}
+	}
+	if pc, ok := f.future.(PollClose); ok {
+		return pc.PollClose(event)
+	}
+	return nil
+}
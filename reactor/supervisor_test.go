@@ -0,0 +1,117 @@
+package reactor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/moontrade/kirana/pkg/counter"
+)
+
+type flakyChild struct {
+	starts *counter.Counter
+	fails  int64
+}
+
+func (f *flakyChild) Poll(ctx Context) error {
+	if ctx.Reason == ReasonStart {
+		if f.starts.Incr() <= f.fails {
+			return errors.New("flaky")
+		}
+	}
+	return nil
+}
+
+func waitFor(t *testing.T, what string, fn func() bool) {
+	deadline := time.Now().Add(time.Second * 5)
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for", what)
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func newSupervisorReactor(t *testing.T) *Reactor {
+	r, err := NewReactor(Config{Level1Wheel: NewWheel(Millis25)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Start()
+	return r
+}
+
+func TestSupervisorOneForOne(t *testing.T) {
+	r := newSupervisorReactor(t)
+	defer r.Close(context.Background())
+
+	var flaky, stable counter.Counter
+	s := NewSupervisor(SupervisorConfig{
+		Strategy:   OneForOne,
+		BackoffMin: time.Millisecond * 10,
+	},
+		ChildSpec{Name: "flaky", Start: func() Future {
+			return &flakyChild{starts: &flaky, fails: 2}
+		}},
+		ChildSpec{Name: "stable", Start: func() Future {
+			return &flakyChild{starts: &stable}
+		}},
+	)
+	if _, err := r.Spawn(s); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "flaky restarts", func() bool { return flaky.Load() == 3 })
+	time.Sleep(time.Millisecond * 100)
+	if stable.Load() != 1 {
+		t.Fatal("expected stable child to start once, got", stable.Load())
+	}
+}
+
+func TestSupervisorOneForAll(t *testing.T) {
+	r := newSupervisorReactor(t)
+	defer r.Close(context.Background())
+
+	var flaky, stable counter.Counter
+	s := NewSupervisor(SupervisorConfig{
+		Strategy:   OneForAll,
+		BackoffMin: time.Millisecond * 10,
+	},
+		ChildSpec{Name: "stable", Start: func() Future {
+			return &flakyChild{starts: &stable}
+		}},
+		ChildSpec{Name: "flaky", Start: func() Future {
+			return &flakyChild{starts: &flaky, fails: 1}
+		}},
+	)
+	if _, err := r.Spawn(s); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "all restarted", func() bool { return flaky.Load() == 2 && stable.Load() == 2 })
+}
+
+func TestSupervisorMaxRestarts(t *testing.T) {
+	r := newSupervisorReactor(t)
+	defer r.Close(context.Background())
+
+	var failing counter.Counter
+	child := NewSupervisor(SupervisorConfig{
+		MaxRestarts: 2,
+		BackoffMin:  time.Millisecond * 10,
+	}, ChildSpec{Name: "failing", Start: func() Future {
+		return &flakyChild{starts: &failing, fails: 1 << 30}
+	}})
+	parent := NewSupervisor(SupervisorConfig{}, ChildSpec{
+		Name:    "child",
+		Restart: Temporary,
+		Start:   func() Future { return child },
+	})
+	if _, err := r.Spawn(parent); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "escalation", func() bool { return failing.Load() == 3 })
+	time.Sleep(time.Millisecond * 50)
+	if !errors.Is(child.Err(), ErrMaxRestarts) {
+		t.Fatal("expected ErrMaxRestarts, got", child.Err())
+	}
+}
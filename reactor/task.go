@@ -1,6 +1,7 @@
 package reactor
 
 import (
+	"context"
 	"github.com/moontrade/kirana/pkg/pmath"
 	"github.com/moontrade/kirana/pkg/spinlock"
 	"github.com/moontrade/kirana/pkg/util"
@@ -48,10 +49,28 @@ type Task struct {
 	wakes     int64
 	wakeAfter time.Duration
 	polls     int64
+	pollsDur  int64
+	stats     *FuncStats
 	pid       int64
+	restarts  int
+	restartAt int64
+	scheduled bool
+	priority  Priority
+	cron      *taskSchedule
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closers   []func()
 	head      *TaskSlot
 	mu        spinlock.Mutex
 	stop      bool
+
+	// Poll budget of the current tick. See Config.PollBudget.
+	budgetTick int64
+	tickPolls  int
+	exhausted  bool
+	overruns   int64
+	deferrals  int64
+	maxPollDur int64
 }
 
 func (t *Task) CheckGID() bool {
@@ -77,11 +96,38 @@ func (t *Task) linkSlot(slot *TaskSlot) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	head := t.head
-	if head == nil {
-		t.head = slot
-	} else {
+	slot.prev = nil
+	slot.next = head
+	if head != nil {
 		head.prev = slot
-		slot.next = head
+	}
+	t.head = slot
+}
+
+// taskSlots returns a snapshot of the TaskSet slots the Task belongs to.
+func (t *Task) taskSlots() []*TaskSlot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var slots []*TaskSlot
+	for next := t.head; next != nil; next = next.next {
+		slots = append(slots, next)
+	}
+	return slots
+}
+
+// rehome moves the Task's TaskSet slots to the WakeList of its current Reactor.
+// Slots that cannot be moved are left in place and their wakes are forwarded.
+func (t *Task) rehome(slots []*TaskSlot) {
+	r := t.reactor
+	for _, slot := range slots {
+		wl := slot.slot
+		if wl == nil || wl.reactor == r || wl.owner == nil {
+			continue
+		}
+		if _, err := wl.owner.Add(slot.future); err != nil {
+			continue
+		}
+		slot.Remove()
 	}
 }
 
@@ -140,10 +186,50 @@ func (t *Task) LastPoll() int64         { return t.lastPoll }
 func (t *Task) Interval() time.Duration { return t.interval }
 func (t *Task) Wakes() int64            { return t.wakes }
 func (t *Task) Polls() int64            { return t.polls }
+
+// PollsDur is the total time spent polling when Config.PollBudget is set or the
+// Reactor is profiling.
+func (t *Task) PollsDur() time.Duration { return time.Duration(t.pollsDur) }
+func (t *Task) Restarts() int           { return t.restarts }
+func (t *Task) Priority() Priority      { return t.priority }
 func (t *Task) Stop() bool              { return t.stop }
 func (t *Task) SetStop(stop bool) {
 	t.stop = stop
 }
+
+// Schedule returns the Schedule of a Task spawned with SpawnSchedule or nil.
+func (t *Task) Schedule() Schedule {
+	if t.cron == nil {
+		return nil
+	}
+	return t.cron.schedule
+}
+
+// Context returns the standard context bound to the Task's lifetime. It is cancelled
+// when the Task stops. Must be called on the Reactor goroutine.
+func (t *Task) Context() context.Context {
+	if t.ctx == nil {
+		t.ctx, t.cancel = context.WithCancel(context.Background())
+	}
+	return t.ctx
+}
+
+// closeAll runs the funcs registered to close resources owned by the Task such as
+// its Mailboxes.
+func (t *Task) closeAll() {
+	closers := t.closers
+	t.closers = nil
+	for _, fn := range closers {
+		fn()
+	}
+}
+
+func (t *Task) cancelContext() {
+	if t.cancel != nil {
+		t.cancel()
+	}
+}
+
 func (t *Task) Wake() error {
 	reactor := t.reactor
 	if reactor != nil {
@@ -208,6 +294,13 @@ func (tq *taskSwapList) alloc(task *Task, wake bool) *taskSwapSlot {
 	return tq.get(idx).set(task, wake)
 }
 
+// allocFunc allocates a wake slot that invokes fn instead of polling task.
+func (tq *taskSwapList) allocFunc(task *Task, fn func()) *taskSwapSlot {
+	slot := tq.alloc(task, true)
+	slot.fn = fn
+	return slot
+}
+
 func (tq *taskSwapList) get(idx int) *taskSwapSlot {
 	return (*taskSwapSlot)(unsafe.Add(tq.ptr, idx*taskSlotSize))
 }
@@ -218,7 +311,11 @@ func (tq *taskSwapList) clear(idx int) {
 	}
 	tq.size--
 	if idx < tq.size {
-		tq.get(idx).task = tq.get(tq.size).clear()
+		last := tq.get(tq.size)
+		slot := tq.get(idx)
+		slot.wake = last.wake
+		slot.fn = last.fn
+		slot.task = last.clear()
 	} else {
 		tq.get(idx).clear()
 	}
@@ -233,27 +330,37 @@ func (tq *taskSwapList) iterate(
 		task *Task,
 	) bool) {
 	idx := 0
-	for idx < tq.size {
+	// Slots allocated by fn are appended after end and are not polled until the
+	// list is ticked again.
+	end := tq.size
+	for idx < end {
 		slot := tq.get(idx)
 		if slot.wake {
 			fn(now, tq, slot, slot.task)
-			tq.size--
-			if idx < tq.size {
-				last := tq.get(tq.size)
-				slot.task = last.task
-				slot.wake = last.wake
-			} else {
-				slot.task = nil
-				slot.wake = false
-			}
+			end--
+			tq.remove(idx, end)
 		} else if !fn(now, tq, slot, slot.task) {
-			tq.size--
+			end--
+			tq.remove(idx, end)
 		} else {
 			idx++
 		}
 	}
 }
 
+// remove replaces the slot at idx with the slot at last, the last one being
+// iterated, which is replaced by the last slot of the list.
+func (tq *taskSwapList) remove(idx, last int) {
+	tq.size--
+	if idx != last {
+		*tq.get(idx) = *tq.get(last)
+	}
+	if last != tq.size {
+		*tq.get(last) = *tq.get(tq.size)
+	}
+	tq.get(tq.size).clear()
+}
+
 type taskSwapSlot struct {
 	task *Task
 	fn   func()
@@ -269,6 +376,7 @@ func (t *taskSwapSlot) set(task *Task, wake bool) *taskSwapSlot {
 func (t *taskSwapSlot) clear() *Task {
 	r := t.task
 	t.wake = false
+	t.fn = nil
 	t.task = nil
 	return r
 }
@@ -87,7 +87,12 @@ func (tl *TaskSet) Stop() int64 {
 }
 
 func (tl *TaskSet) Wake() error {
-	slots := tl.slots.slots
+	lists := tl.slots
+	if lists == nil {
+		// Nothing was ever added.
+		return nil
+	}
+	slots := lists.slots
 	var wl *WakeList
 	for i := 0; i < len(slots); i++ {
 		wl = slots[i]
@@ -113,7 +118,7 @@ func (tl *TaskSet) SpawnOn(reactor *Reactor, future FutureTask) (*Task, error) {
 		return nil, errors.New("nil future")
 	}
 	task := taskPool.Get()
-	task.init(reactor.idCounter.Incr(), reactor, future)
+	task.init(idCounter.Incr(), reactor, future)
 	future.SetTask(task)
 	var err error
 	_, err = tl.Add(future)
@@ -121,8 +126,8 @@ func (tl *TaskSet) SpawnOn(reactor *Reactor, future FutureTask) (*Task, error) {
 		taskPool.Put(task)
 		return nil, err
 	}
-	if !reactor.spawnQ.Enqueue(task) {
-		return nil, ErrQueueFull
+	if err = reactor.enqueueSpawn(task); err != nil {
+		return nil, err
 	}
 	return task, nil
 }
@@ -146,7 +151,7 @@ func (tl *TaskSet) SpawnIntervalOn(
 		return nil, errors.New("nil future")
 	}
 	task := taskPool.Get()
-	task.init(reactor.idCounter.Incr(), reactor, future)
+	task.init(idCounter.Incr(), reactor, future)
 	task.interval = interval
 	future.SetTask(task)
 	var err error
@@ -155,8 +160,8 @@ func (tl *TaskSet) SpawnIntervalOn(
 		taskPool.Put(task)
 		return nil, err
 	}
-	if !reactor.spawnQ.Enqueue(task) {
-		return nil, ErrQueueFull
+	if err = reactor.enqueueSpawn(task); err != nil {
+		return nil, err
 	}
 	return task, nil
 }
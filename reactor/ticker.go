@@ -15,6 +15,7 @@ import (
 	"errors"
 	"github.com/moontrade/kirana/pkg/counter"
 	"github.com/moontrade/kirana/pkg/cow"
+	"github.com/moontrade/kirana/pkg/histogram"
 	"github.com/moontrade/kirana/pkg/timex"
 	"github.com/moontrade/unsafe/cgo"
 	"os"
@@ -39,25 +40,74 @@ type Ticker struct {
 	tickDurMax counter.Counter
 	skews      counter.Counter
 	skewMax    counter.Counter
+	early      counter.Counter
+	jitter     *histogram.Histogram
 	notifyList cow.Slice[*TickListener]
+	cpus       []int
+	affinity   atomic.Pointer[error]
 	stop       int32
 	mu         sync.Mutex
 	wg         sync.WaitGroup
 }
 
 func StartTicker(duration time.Duration) *Ticker {
+	return StartTickerOn(duration, nil)
+}
+
+// StartTickerOn starts a Ticker with its OS thread pinned to cpus.
+func StartTickerOn(duration time.Duration, cpus []int) *Ticker {
 	if duration < time.Microsecond {
 		duration = time.Microsecond
 	}
 	t := &Ticker{
 		tick:       duration,
 		notifyList: *cow.NewSlice[*TickListener](),
+		jitter:     histogram.New(JitterBounds()...),
+		cpus:       cpus,
 	}
 	t.wg.Add(1)
 	go t.run()
 	return t
 }
 
+// AffinityErr returns the error pinning the Ticker's thread to its CPUs if any.
+func (t *Ticker) AffinityErr() error { return loadErr(&t.affinity) }
+
+// JitterBounds returns the bounds of the Ticker jitter histogram from 64ns to ~1s
+// with 1/8 relative precision.
+func JitterBounds() []int64 {
+	return histogram.LogLinear(64, int64(time.Second), 8)
+}
+
+// Jitter returns the histogram of the absolute difference in nanoseconds between the
+// scheduled and actual time of each tick.
+func (t *Ticker) Jitter() *histogram.Histogram {
+	return t.jitter
+}
+
+// Early returns the number of ticks that fired before their scheduled time.
+func (t *Ticker) Early() int64 {
+	return t.early.Load()
+}
+
+// Skews returns the number of times the Ticker fell behind and skipped ticks.
+func (t *Ticker) Skews() int64 {
+	return t.skews.Load()
+}
+
+func (t *Ticker) observeJitter(jitter int64) {
+	if jitter < 0 {
+		t.early.Incr()
+		jitter = -jitter
+	}
+	t.jitter.Observe(jitter)
+}
+
+// Now returns the monotonic nano time.
+func (t *Ticker) Now() int64 {
+	return timex.NanoTime()
+}
+
 func (t *Ticker) Close() error {
 	if !atomic.CompareAndSwapInt32(&t.stop, 0, 1) {
 		return os.ErrClosed
@@ -83,18 +133,21 @@ func (t *Ticker) remove(tl *TickListener) {
 func (t *Ticker) run() {
 	defer t.wg.Done()
 	runtime.LockOSThread()
-	defer runtime.UnlockOSThread()
+	if !setAffinity(t.cpus, &t.affinity) {
+		defer runtime.UnlockOSThread()
+	}
 	var (
 		//Tick    int64
-		started = timex.NanoTime()
-		begin   = started
-		end     int64
-		elapsed int64
-		sleep   time.Duration
-		tickDur = int64(t.tick)
-		next    = begin + tickDur
-		list    = &t.notifyList
-		msg     = Tick{Dur: t.tick, Precision: t.tick}
+		started   = timex.NanoTime()
+		begin     = started
+		end       int64
+		elapsed   int64
+		sleep     time.Duration
+		scheduled int64
+		tickDur   = int64(t.tick)
+		next      = begin + tickDur
+		list      = &t.notifyList
+		msg       = Tick{Dur: t.tick, Precision: t.tick}
 	)
 	notify := func(ln *TickListener) bool {
 		ln.tick(msg)
@@ -128,6 +181,7 @@ func (t *Ticker) run() {
 			t.ticks.Add(ticksBehind)
 			next += tickDur * ticksBehind
 			sleep = time.Duration(next - end)
+			scheduled = next
 			next += tickDur
 
 			//logger.Warn("behind", ticksBehind, "ticker is behind %d ticks %s time sleeping for %s", ticksBehind, time.Duration(timeBehind), sleep)
@@ -155,6 +209,7 @@ func (t *Ticker) run() {
 			//}
 		} else {
 			sleep = time.Duration(next - end)
+			scheduled = next
 			next += tickDur
 			if sleep > 0 {
 				park(sleep)
@@ -162,6 +217,7 @@ func (t *Ticker) run() {
 		}
 
 		begin = timex.NanoTime()
+		t.observeJitter(begin - scheduled)
 	}
 }
 
@@ -170,8 +226,13 @@ func park(duration time.Duration) {
 	cgo.NonBlockingSleep(duration)
 }
 
+// tickSource is a Clock that TickListeners are registered with.
+type tickSource interface {
+	remove(tl *TickListener)
+}
+
 type TickListener struct {
-	ticker        *Ticker
+	ticker        tickSource
 	owner         interface{}
 	ch            chan int64
 	chOwned       bool
@@ -182,6 +243,7 @@ type TickListener struct {
 	notifySuccess counter.Counter
 	notifyFails   counter.Counter
 	notifyPanics  counter.Counter
+	blocking      bool
 	mu            sync.Mutex
 }
 
@@ -206,7 +268,7 @@ func (tl *TickListener) Close() error {
 }
 
 func newTickListener(
-	ticker *Ticker,
+	ticker tickSource,
 	duration time.Duration,
 	owner interface{},
 	ch chan int64,
@@ -264,6 +326,10 @@ func (tl *TickListener) doNotify() {
 			//logger.WarnErr(util.PanicToError(e), "panic")
 		}
 	}()
+	if tl.blocking {
+		tl.notifyBlocking()
+		return
+	}
 	select {
 	case tl.ch <- tl.next.Tick:
 		tl.notifySuccess++
@@ -271,3 +337,23 @@ func (tl *TickListener) doNotify() {
 		tl.notifyFails++
 	}
 }
+
+// notifyBlocking waits for the owner to receive the tick or the listener to close.
+func (tl *TickListener) notifyBlocking() {
+	for {
+		select {
+		case tl.ch <- tl.next.Tick:
+			tl.notifySuccess++
+			return
+		default:
+			tl.mu.Lock()
+			closed := tl.ticker == nil
+			tl.mu.Unlock()
+			if closed {
+				tl.notifyFails++
+				return
+			}
+			runtime.Gosched()
+		}
+	}
+}
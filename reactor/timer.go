@@ -0,0 +1,199 @@
+package reactor
+
+import (
+	"container/heap"
+	"errors"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	timerPending int32 = 0
+	timerFired   int32 = 1
+	timerStopped int32 = 2
+)
+
+// Timer is a one-shot timer on a Reactor. Timers are kept in a min-heap on the
+// Reactor goroutine which covers delays of any length. Timers due before the next
+// tick are fired by a runtime timer for sub-tick precision unless the Reactor uses
+// a Clock other than the global Ticker.
+type Timer struct {
+	reactor *Reactor
+	task    *Task
+	at      int64
+	fn      func()
+	fire    func(now int64)
+	index   int
+	state   int32
+}
+
+// Deadline is the Reactor time the Timer fires at.
+func (t *Timer) Deadline() int64 { return t.at }
+
+// Stop prevents the Timer from firing. Returns false if it already fired or was stopped.
+func (t *Timer) Stop() bool {
+	if !atomic.CompareAndSwapInt32(&t.state, timerPending, timerStopped) {
+		return false
+	}
+	r := t.reactor
+	if r.CheckGID() {
+		r.removeTimer(t)
+	} else {
+		r.Invoke(func() {
+			r.removeTimer(t)
+		})
+	}
+	return true
+}
+
+// AfterFunc invokes fn on the Reactor goroutine after d.
+func (r *Reactor) AfterFunc(d time.Duration, fn func()) (*Timer, error) {
+	if d < 0 {
+		d = 0
+	}
+	return r.At(r.nanotime()+int64(d), fn)
+}
+
+// At invokes fn on the Reactor goroutine at deadline in Reactor time. See Reactor.Now.
+func (r *Reactor) At(deadline int64, fn func()) (*Timer, error) {
+	if fn == nil {
+		return nil, errors.New("nil func")
+	}
+	if r.IsClosed() {
+		return nil, os.ErrClosed
+	}
+	t := &Timer{reactor: r, at: deadline, fn: fn, index: -1}
+	if r.CheckGID() {
+		r.addTimer(t)
+		return t, nil
+	}
+	if !r.Invoke(func() {
+		r.addTimer(t)
+	}) {
+		return nil, ErrQueueFull
+	}
+	return t, nil
+}
+
+// scheduleOverflow schedules task on the timer heap for delays beyond the Level3Wheel.
+// Intervals are rescheduled after each poll.
+func (r *Reactor) scheduleOverflow(task *Task, delay time.Duration, wake bool) {
+	id := task.id
+	r.addTimer(&Timer{
+		reactor: r,
+		task:    task,
+		at:      r.now + int64(delay),
+		index:   -1,
+		fire: func(now int64) {
+			if task.id != id || task.reactor != r || task.stop {
+				return
+			}
+			if wake {
+				r.pollWake(now, task)
+			} else if r.pollInterval(now, delay, task) {
+				r.scheduleOverflow(task, delay, false)
+			}
+		},
+	})
+}
+
+func (r *Reactor) addTimer(t *Timer) {
+	if atomic.LoadInt32(&t.state) != timerPending {
+		return
+	}
+	heap.Push(&r.timers, t)
+	r.timersAdded.Incr()
+}
+
+func (r *Reactor) removeTimer(t *Timer) {
+	if t.index >= 0 && t.index < len(r.timers) && r.timers[t.index] == t {
+		heap.Remove(&r.timers, t.index)
+		r.timersStopped.Incr()
+	}
+}
+
+// runTimers fires every Timer due by now.
+func (r *Reactor) runTimers(now int64) {
+	for len(r.timers) > 0 && r.timers[0].at <= now {
+		t := heap.Pop(&r.timers).(*Timer)
+		if !atomic.CompareAndSwapInt32(&t.state, timerPending, timerFired) {
+			continue
+		}
+		r.timersFired.Incr()
+		if lag := now - t.at; lag > 0 {
+			r.timersLagDur.Add(lag)
+		}
+		if t.fire != nil {
+			t.fire(now)
+		} else {
+			r.invoke(t.fn)
+		}
+	}
+}
+
+// armTimer arms the runtime timer when the earliest Timer is due before the next tick.
+func (r *Reactor) armTimer(now int64) {
+	if len(r.timers) == 0 || r.clock != nil {
+		return
+	}
+	at := r.timers[0].at
+	wait := at - now
+	if wait >= int64(r.tickDur) {
+		return
+	}
+	if armed := atomic.LoadInt64(&r.hrTimerAt); armed != 0 && armed <= at {
+		return
+	}
+	atomic.StoreInt64(&r.hrTimerAt, at)
+	if r.hrTimer == nil {
+		r.hrTimer = time.AfterFunc(time.Duration(wait), r.timerWake)
+	} else {
+		r.hrTimer.Reset(time.Duration(wait))
+	}
+}
+
+// timerWake is called by the runtime timer.
+func (r *Reactor) timerWake() {
+	atomic.StoreInt64(&r.hrTimerAt, 0)
+	select {
+	case r.wakeCh <- 0:
+	default:
+	}
+}
+
+func (r *Reactor) stopTimers() {
+	if r.hrTimer != nil {
+		r.hrTimer.Stop()
+	}
+	for _, t := range r.timers {
+		atomic.CompareAndSwapInt32(&t.state, timerPending, timerStopped)
+	}
+	r.timers = nil
+}
+
+type timerHeap []*Timer
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].at < h[j].at }
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x any) {
+	t := x.(*Timer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() any {
+	old := *h
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	t.index = -1
+	*h = old[:n-1]
+	return t
+}
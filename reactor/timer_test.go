@@ -0,0 +1,106 @@
+package reactor
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type sleeper struct {
+	after time.Duration
+	polls int
+}
+
+func (s *sleeper) Poll(ctx Context) error {
+	s.polls++
+	if s.polls == 1 {
+		ctx.WakeAfter(s.after)
+	}
+	return nil
+}
+
+func TestReactorTimers(t *testing.T) {
+	clock := NewManualClock(time.Millisecond * 25)
+	r, err := NewReactor(Config{
+		Level1Wheel: NewWheel(Millis25),
+		Clock:       clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fired, stopped int
+	if _, err = r.AfterFunc(time.Millisecond*10, func() { fired++ }); err != nil {
+		t.Fatal(err)
+	}
+	timer, err := r.AfterFunc(time.Millisecond*20, func() { stopped++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.onWakeMessage(0)
+	if fired != 0 || len(r.timers) != 2 {
+		t.Fatalf("expected 2 pending timers, fired=%d pending=%d", fired, len(r.timers))
+	}
+	if !timer.Stop() || timer.Stop() {
+		t.Fatal("expected a single successful Stop")
+	}
+	clock.Advance(time.Millisecond * 10)
+	r.onWakeMessage(0)
+	if fired != 1 {
+		t.Fatalf("expected timer to fire, fired=%d", fired)
+	}
+	clock.Advance(time.Millisecond * 20)
+	r.onWakeMessage(0)
+	if stopped != 0 || len(r.timers) != 0 {
+		t.Fatalf("stopped timer fired=%d pending=%d", stopped, len(r.timers))
+	}
+}
+
+func TestReactorTimerOverflow(t *testing.T) {
+	clock := NewManualClock(time.Millisecond * 25)
+	r, err := NewReactor(Config{
+		Level1Wheel: NewWheel(Millis25),
+		Clock:       clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := &sleeper{after: time.Hour * 25}
+	if _, err = r.Spawn(task); err != nil {
+		t.Fatal(err)
+	}
+	r.onWakeMessage(0)
+	if task.polls != 1 || len(r.timers) != 1 {
+		t.Fatalf("expected wake on the timer heap, polls=%d pending=%d", task.polls, len(r.timers))
+	}
+	clock.Advance(time.Hour * 25)
+	r.onWakeMessage(0)
+	if task.polls != 2 {
+		t.Fatalf("expected overflow wake, polls=%d", task.polls)
+	}
+}
+
+func TestReactorAfterFuncSubTick(t *testing.T) {
+	r, err := NewReactor(Config{
+		Level1Wheel: NewWheel(Millis250),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Start()
+	defer r.Close(context.Background())
+	var fired int64
+	begin := time.Now()
+	if _, err = r.AfterFunc(time.Millisecond*2, func() {
+		atomic.StoreInt64(&fired, int64(time.Since(begin)))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(&fired) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if elapsed := time.Duration(atomic.LoadInt64(&fired)); elapsed == 0 || elapsed >= time.Millisecond*100 {
+		t.Fatalf("expected sub-tick fire, took %s", elapsed)
+	}
+}
@@ -204,10 +204,7 @@ func (w *Wheel) schedule(task *Task, duration time.Duration, wake bool) bool {
 	if duration > w.maxDur {
 		return false
 	}
-	var (
-		current   = uint64(w.current)
-		currentM1 = uint64(current) - 1
-	)
+	current := uint64(w.current)
 	for i := 0; i < len(w.durations); i++ {
 		if duration <= w.durations[i] {
 			list := w.wheel[i]
@@ -216,7 +213,9 @@ func (w *Wheel) schedule(task *Task, duration time.Duration, wake bool) bool {
 				w.size++
 				return true
 			}
-			slot := list[currentM1%uint64(len(list))]
+			// The slot ticked last. Adding the length keeps it from wrapping before
+			// the first tick.
+			slot := list[(current+uint64(len(list))-1)%uint64(len(list))]
 			slot.alloc(task, wake)
 			w.size++
 			return true
@@ -224,3 +223,78 @@ func (w *Wheel) schedule(task *Task, duration time.Duration, wake bool) bool {
 	}
 	return false
 }
+
+// floor returns the longest duration that wakes at least a tick before d or 0.
+func (w *Wheel) floor(d time.Duration) time.Duration {
+	if w.tickDur <= 0 {
+		return 0
+	}
+	d -= w.tickDur
+	for i := len(w.durations) - 1; i >= 0; i-- {
+		if w.durations[i] <= d {
+			return w.durations[i]
+		}
+	}
+	return 0
+}
+
+// scheduleFunc places fn to be invoked after duration which must be one of the
+// durations of the wheel.
+func (w *Wheel) scheduleFunc(task *Task, duration time.Duration, fn func()) bool {
+	for i := 0; i < len(w.durations); i++ {
+		if w.durations[i] != duration {
+			continue
+		}
+		list := w.wheel[i]
+		list[(uint64(w.current)+uint64(len(list))-1)%uint64(len(list))].allocFunc(task, fn)
+		w.size++
+		return true
+	}
+	return false
+}
+
+// scheduleAt places an interval task into the list for duration so that it is
+// polled after phase more ticks. phase 0 means the next tick.
+func (w *Wheel) scheduleAt(task *Task, duration time.Duration, phase int64) bool {
+	for i := 0; i < len(w.durations); i++ {
+		if w.durations[i] != duration {
+			continue
+		}
+		list := w.wheel[i]
+		slot := list[(uint64(w.current)+uint64(phase))%uint64(len(list))]
+		slot.alloc(task, false)
+		w.size++
+		return true
+	}
+	return false
+}
+
+// migrate removes up to max interval tasks from the wheel for which fn returns true.
+// fn is given the phase of the task which is the number of ticks remaining before
+// its next poll with 0 being the next tick.
+func (w *Wheel) migrate(max int, fn func(task *Task, interval time.Duration, phase int64) bool) int {
+	count := 0
+	for i := 0; i < len(w.wheel) && count < max; i++ {
+		list := w.wheel[i]
+		size := uint64(len(list))
+		current := uint64(w.current) % size
+		for k := 0; k < len(list) && count < max; k++ {
+			slots := list[k]
+			phase := int64((uint64(k) + size - current) % size)
+			idx := 0
+			for idx < slots.size && count < max {
+				slot := slots.get(idx)
+				task := slot.task
+				if slot.wake || task == nil || task.stop || task.interval != slots.dur ||
+					!fn(task, slots.dur, phase) {
+					idx++
+					continue
+				}
+				slots.clear(idx)
+				w.size--
+				count++
+			}
+		}
+	}
+	return count
+}